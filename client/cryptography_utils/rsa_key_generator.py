@@ -1,10 +1,11 @@
 #!/usr/bin/env python3
 
+import base64
 import os
 
 from cryptography.hazmat.backends import default_backend
-from cryptography.hazmat.primitives import serialization
-from cryptography.hazmat.primitives.asymmetric import rsa
+from cryptography.hazmat.primitives import hashes, serialization
+from cryptography.hazmat.primitives.asymmetric import padding, rsa
 
 
 def generate_rsa_key_pair(private_key_filepath: str) -> tuple[str, str]:
@@ -70,3 +71,17 @@ def read_rsa_private_key_from_pem(file_path: str) -> rsa.RSAPrivateKey:
     )
 
     return private_key
+
+
+def sign_message(private_key: rsa.RSAPrivateKey, message: str) -> str:
+    """Sign a message with the private key using PKCS#1 v1.5 and SHA-256
+
+    :param private_key: Private key to sign the message with
+    :param message: Message to sign
+    :return: base64 encoded signature
+    """
+    signature = private_key.sign(
+        message.encode("utf-8"), padding.PKCS1v15(), hashes.SHA256()
+    )
+
+    return base64.b64encode(signature).decode("utf-8")
@@ -11,6 +11,9 @@ class UserAccess(Enum):
     ReadWriteAccess = 2
     OwnerAccess = 3
     NoAccess = 4
+    TriageAccess = 5
+    WriteAccess = 6
+    AdminAccess = 7
 
 
 class Commit(BaseModel):
@@ -21,6 +24,10 @@ class Commit(BaseModel):
     parentHashes: list[str]
     timestamp: datetime
     storageHashes: dict[str, str]
+    signature: str | None = None
+    signatureFormat: str | None = None
+    verified: bool = False
+    keyEpoch: int | None = None
 
 
 class CommitWithBranch(Commit):
@@ -34,6 +41,7 @@ class Push(BaseModel):
 
 class Branch(BaseModel):
     name: str
+    head: str = ""
     commits: dict[str, Commit]
 
 
@@ -42,6 +50,8 @@ class AccessLog(BaseModel):
     authorized: str
     timestamp: datetime
     userAccess: UserAccess
+    expiresAt: datetime | None = None
+    visibility: str | None = None
 
 
 class Repository(BaseModel):
@@ -52,3 +62,7 @@ class Repository(BaseModel):
     access: dict[str, UserAccess]
     branches: dict[str, Branch]
     accessLogs: list[AccessLog]
+    visibility: str = "private"
+    privateCollection: str | None = None
+    keyEpoch: int = 0
+    requiredApprovals: int = 0
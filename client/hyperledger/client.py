@@ -24,12 +24,15 @@ org_config = {
 }
 
 
-def invoke_function(function_name: str, args: list[str]) -> str:
+def invoke_function(
+    function_name: str, args: list[str], transient_map: dict[str, bytes] | None = None
+) -> str:
     """
     Invoke a function on the blockchain
 
     :param function_name: Name of function to invoke
     :param args: Arguments to pass in for the function called
+    :param transient_map: Private data to pass in, which is not recorded on the ledger
     :return: Response obtained from blockchain
     """
     response = loop.run_until_complete(
@@ -40,7 +43,7 @@ def invoke_function(function_name: str, args: list[str]) -> str:
             fcn=function_name,
             args=args,
             cc_name="contract",
-            transient_map=None,
+            transient_map=transient_map,
             wait_for_event=True,
             raise_on_error=True,
         )
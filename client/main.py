@@ -10,9 +10,9 @@ import pytz
 from hfc.fabric.chaincode import ChaincodeExecutionError
 
 from cryptography_utils.rsa_key_generator import (
-    extract_public_key_bytes,
     generate_rsa_key_pair,
     read_rsa_private_key_from_pem,
+    sign_message,
 )
 from data.models import AccessLog, Branch, Commit, Repository, UserAccess
 from git_client.client import (
@@ -44,6 +44,45 @@ def get_arg_at_position(
         return default_value
 
 
+def is_private_repo(author: str, repo_name: str) -> bool:
+    """
+    Check if the content of a repo is stored in a private data collection
+
+    :param author: Author of the repo
+    :param repo_name: Name of the repo
+    :return: True if the repo is private to an organization
+    """
+    repo = json.loads(invoke_function("queryRepo", [author, repo_name]))
+    return bool(repo.get("privateCollection"))
+
+
+def invoke_repo_write(
+    function_name: str,
+    args: list[str],
+    payload_key: str,
+    payload: str,
+    private: bool,
+    options: dict[str, str] | None = None,
+) -> str:
+    """
+    Invoke a function writing content to a repo, passing the content through the transient map for private repos
+
+    :param function_name: Name of function to invoke
+    :param args: Arguments to pass in for the function called, except for the content
+    :param payload_key: Key of the content in the transient map
+    :param payload: Content to write
+    :param private: Whether the repo is private to an organization
+    :param options: Optional arguments following the content, passed under their names in the transient map for private repos
+    :return: Response obtained from blockchain
+    """
+    options = options or {}
+    if private:
+        transient_map = {payload_key: payload.encode()}
+        transient_map.update({key: value.encode() for key, value in options.items()})
+        return invoke_function(function_name, args, transient_map=transient_map)
+    return invoke_function(function_name, args + [payload] + list(options.values()))
+
+
 if __name__ == "__main__":
     command = sys.argv[1]
     other_args = sys.argv[2:]
@@ -55,7 +94,14 @@ if __name__ == "__main__":
             private_key_path = f".ssh/{name}_{datetime.now().timestamp()}.pem"
 
             private_key, public_key = generate_rsa_key_pair(private_key_path)
-            response = invoke_function("registerNewUser", [name, email, public_key])
+            response = invoke_function(
+                "registerNewUser",
+                [name, public_key],
+                transient_map={
+                    "email": email.encode(),
+                    "salt": os.urandom(16).hex().encode(),
+                },
+            )
             print(f"Your private key is located at {private_key_path}")
         case "login":
             # Args: name, private_key_path
@@ -63,11 +109,13 @@ if __name__ == "__main__":
             rsa_private_key_path = other_args[1]
 
             private_key = read_rsa_private_key_from_pem(rsa_private_key_path)
-            public_key = extract_public_key_bytes(private_key.public_key()).decode(
-                "utf-8"
-            )
 
-            response = invoke_function("logIn", [name, public_key])
+            challenge = json.loads(invoke_function("requestLoginChallenge", [name]))
+            signature = sign_message(private_key, challenge["nonce"])
+
+            response = invoke_function(
+                "logIn", [name, challenge["challengeID"], signature]
+            )
             logged_in_user = json.loads(
                 invoke_function(
                     "whoAmI",
@@ -96,6 +144,7 @@ if __name__ == "__main__":
         case "init":
             repo_name = other_args[0]
             repo_parent_directory = get_arg_at_position(other_args, 1, os.getcwd())
+            private = get_arg_at_position(other_args, 2, "false").lower() == "true"
 
             logged_in_user = json.loads(
                 invoke_function(
@@ -122,22 +171,22 @@ if __name__ == "__main__":
             ]
             repository_from_blockchain.access = {author: UserAccess.OwnerAccess}
 
-            response = invoke_function(
-                "addNewRepo",
-                [repository_from_blockchain.json()],
-            )
+            if private:
+                response = invoke_function(
+                    "addNewRepo",
+                    [],
+                    transient_map={"repo": repository_from_blockchain.json().encode()},
+                )
+            else:
+                response = invoke_function(
+                    "addNewRepo",
+                    [repository_from_blockchain.json()],
+                )
         case "clone":
             author = other_args[0]
             repo_name = other_args[1]
             repo_parent_directory = get_arg_at_position(other_args, 2, os.getcwd())
 
-            logged_in_user = json.loads(
-                invoke_function(
-                    "whoAmI",
-                    [],
-                )
-            )
-
             response = invoke_function("clone", other_args[0:2])
             repository_from_blockchain = Repository.model_validate(json.loads(response))
             initialize_repo_from_chaincode_structure(
@@ -162,8 +211,12 @@ if __name__ == "__main__":
             )
 
             try:
-                response = invoke_function(
-                    "addNewBranch", [author, repo_name, branch_chaincode.json()]
+                response = invoke_repo_write(
+                    "addNewBranch",
+                    [author, repo_name],
+                    "branch",
+                    branch_chaincode.json(),
+                    is_private_repo(author, repo_name),
                 )
             except ChaincodeExecutionError as e:
                 print("Deleting branch due to execution error", file=sys.stderr)
@@ -251,8 +304,13 @@ if __name__ == "__main__":
             # If a commit is generated, push it to the blockchain
             if commit is not None:
                 try:
-                    response = invoke_function(
-                        "push", [author, repo_name, branch_name, commit.json()]
+                    response = invoke_repo_write(
+                        "push",
+                        [author, repo_name, branch_name],
+                        "commit",
+                        commit.json(),
+                        is_private_repo(author, repo_name),
+                        {"expectedHead": last_commit_hash_from_blockchain},
                     )
                 except ChaincodeExecutionError as e:
                     print("Reverting commit due to execution error", file=sys.stderr)
@@ -292,14 +350,13 @@ if __name__ == "__main__":
             # If there exist commits later than what is on the blockchain, push them to the blockchain
             if commits_to_push:
                 try:
-                    response = invoke_function(
+                    response = invoke_repo_write(
                         "pushMultiple",
-                        [
-                            author,
-                            repo_name,
-                            branch_name,
-                            json.dumps([json.loads(c.json()) for c in commits_to_push]),
-                        ],
+                        [author, repo_name, branch_name],
+                        "commits",
+                        json.dumps([json.loads(c.json()) for c in commits_to_push]),
+                        is_private_repo(author, repo_name),
+                        {"expectedHead": last_commit_hash_from_blockchain},
                     )
                 except ChaincodeExecutionError as e:
                     print("Reverting commits due to execution error", file=sys.stderr)
@@ -315,19 +372,169 @@ if __name__ == "__main__":
                 response = "No commits to push."
 
         case "updateRepoAccess":
-            # Arguments: repo.author repo.name authorizer_name user_access
+            # Arguments: repo.author repo.name authorizer_name user_access optional:expires_at
             author = other_args[0]
             repo_name = other_args[1]
             user_to_authorize = other_args[2]
             access_value = UserAccess[other_args[3]].value
+            expires_at = get_arg_at_position(other_args, 4, "")
 
             response = invoke_function(
                 "updateRepoUserAccess",
-                [author, repo_name, user_to_authorize, access_value],
+                [author, repo_name, user_to_authorize, access_value, expires_at],
             )
+        case "updateRepoVisibility":
+            # Arguments: repo.author repo.name visibility
+            response = invoke_function("updateRepoVisibility", other_args[0:3])
         case "queryRepoAccess":
             # Arguments: repo.author repo.name
             response = invoke_function("queryRepoUserAccess", other_args)
+        case "eraseUserData":
+            # Arguments: none
+            response = invoke_function("eraseUserData", [])
+        case "queryEffectiveRepoAccess":
+            # Arguments: repo.author repo.name
+            response = invoke_function("queryEffectiveRepoAccess", other_args)
+        case "mergeBranch":
+            # Arguments: repo.author repo.name source_branch target_branch
+            # Fast-forwards the target branch; pull it afterwards to update the local branch
+            response = invoke_function(
+                "mergeBranch", other_args[0:4] + ["fast-forward"]
+            )
+        case "openPullRequest":
+            # Arguments: repo.author repo.name target_branch source_repo.author source_repo.name source_branch title description
+            author, repo_name = other_args[0:2]
+            response = invoke_repo_write(
+                "openPullRequest",
+                other_args[0:6],
+                "title",
+                other_args[6],
+                is_private_repo(author, repo_name),
+                {"description": get_arg_at_position(other_args, 7, "")},
+            )
+        case "updatePullRequest":
+            # Arguments: repo.author repo.name pull_request_id optional:title optional:description
+            author, repo_name = other_args[0:2]
+            response = invoke_repo_write(
+                "updatePullRequest",
+                other_args[0:3],
+                "title",
+                get_arg_at_position(other_args, 3, ""),
+                is_private_repo(author, repo_name),
+                {"description": get_arg_at_position(other_args, 4, "")},
+            )
+        case "closePullRequest":
+            # Arguments: repo.author repo.name pull_request_id
+            response = invoke_function("closePullRequest", other_args[0:3])
+        case "mergePullRequest":
+            # Arguments: repo.author repo.name pull_request_id
+            # Only fast-forward merges; otherwise merge the target branch into the source branch first
+            response = invoke_function("mergePullRequest", other_args[0:3])
+        case "queryRepoPullRequests":
+            # Arguments: repo.author repo.name optional:state
+            response = invoke_function("queryRepoPullRequests", other_args[0:3])
+        case "queryUserPullRequests":
+            # Arguments: username optional:state
+            response = invoke_function("queryUserPullRequests", other_args[0:2])
+        case "submitReview":
+            # Arguments: repo.author repo.name pull_request_id kind body optional:private_key_path
+            author, repo_name, pull_request_id, kind, body = other_args[0:5]
+            private_key_path = get_arg_at_position(other_args, 5, "")
+
+            options = {}
+            if private_key_path:
+                pull_requests = json.loads(
+                    invoke_function("queryRepoPullRequests", [author, repo_name])
+                )
+                pull_request = next(
+                    pr for pr in pull_requests if pr["id"] == pull_request_id
+                )
+                # Canonical payload covered by the signature of a review
+                payload = json.dumps(
+                    {
+                        "body": body,
+                        "commit": pull_request["heads"][-1]["commit"],
+                        "kind": kind,
+                        "pullRequestID": pull_request_id,
+                        "repoAuthor": pull_request["repoAuthor"],
+                        "repoName": pull_request["repoName"],
+                        "targetBranch": pull_request["targetBranch"],
+                    },
+                    sort_keys=True,
+                    separators=(",", ":"),
+                    ensure_ascii=False,
+                )
+                private_key = read_rsa_private_key_from_pem(private_key_path)
+                options["signature"] = sign_message(private_key, payload)
+
+            response = invoke_repo_write(
+                "submitReview",
+                [author, repo_name, pull_request_id, kind],
+                "body",
+                body,
+                is_private_repo(author, repo_name),
+                options,
+            )
+        case "queryPullRequestReviews":
+            # Arguments: repo.author repo.name pull_request_id
+            response = invoke_function("queryPullRequestReviews", other_args[0:3])
+        case "updateRepoRequiredApprovals":
+            # Arguments: repo.author repo.name required_approvals
+            response = invoke_function("updateRepoRequiredApprovals", other_args[0:3])
+        case "createTag":
+            # Arguments: repo.author repo.name tag_name commit_hash optional:message optional:tagger optional:private_key_path
+            # A message makes an annotated tag; signing it needs the tagger, i.e. your username, and your private key
+            author, repo_name, tag_name, commit_hash = other_args[0:4]
+            message = get_arg_at_position(other_args, 4, "")
+            tagger = get_arg_at_position(other_args, 5, "")
+            private_key_path = get_arg_at_position(other_args, 6, "")
+
+            options = {}
+            if message and private_key_path:
+                # Canonical payload covered by the signature of an annotated tag
+                payload = json.dumps(
+                    {
+                        "commit": commit_hash,
+                        "message": message,
+                        "name": tag_name,
+                        "repoAuthor": author,
+                        "repoName": repo_name,
+                        "tagger": tagger,
+                    },
+                    sort_keys=True,
+                    separators=(",", ":"),
+                    ensure_ascii=False,
+                )
+                private_key = read_rsa_private_key_from_pem(private_key_path)
+                options["signature"] = sign_message(private_key, payload)
+
+            response = invoke_repo_write(
+                "createTag",
+                [author, repo_name],
+                "tagName",
+                tag_name,
+                is_private_repo(author, repo_name),
+                {"commit": commit_hash, "message": message, **options},
+            )
+        case "deleteTag":
+            # Arguments: repo.author repo.name tag_name
+            author, repo_name, tag_name = other_args[0:3]
+            response = invoke_repo_write(
+                "deleteTag",
+                [author, repo_name],
+                "tagName",
+                tag_name,
+                is_private_repo(author, repo_name),
+            )
+        case "queryTags":
+            # Arguments: repo.author repo.name
+            response = invoke_function("queryTags", other_args[0:2])
+        case "queryReflog":
+            # Arguments: repo.author repo.name branch_name
+            response = invoke_function("queryReflog", other_args[0:3])
+        case "restoreBranchHead":
+            # Arguments: repo.author repo.name branch_name reflog_entry_id
+            response = invoke_function("restoreBranchHead", other_args[0:4])
         case _:
             raise NotImplementedError("Function not supported!")
 
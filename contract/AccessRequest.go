@@ -0,0 +1,89 @@
+package main
+
+import (
+	"time"
+)
+
+// This enum represents how an access request came to be
+type AccessRequestKind string
+
+const (
+	AccessRequestKindRequest    AccessRequestKind = "request"    // filed by the user wanting access, resolved by an owner
+	AccessRequestKindInvitation AccessRequestKind = "invitation" // sent by an owner, resolved by the invited user
+)
+
+// This enum represents the state of an access request
+type AccessRequestStatus string
+
+const (
+	AccessRequestPending  AccessRequestStatus = "pending"
+	AccessRequestApproved AccessRequestStatus = "approved"
+	AccessRequestDenied   AccessRequestStatus = "denied"
+	AccessRequestAccepted AccessRequestStatus = "accepted"
+	AccessRequestDeclined AccessRequestStatus = "declined"
+
+	// steps of an ownership recovery
+	OwnershipRecoveryProposed  AccessRequestStatus = "recoveryProposed"
+	OwnershipRecoveryApproved  AccessRequestStatus = "recoveryApproved"
+	OwnershipRecoveryCancelled AccessRequestStatus = "recoveryCancelled"
+	OwnershipRecovered         AccessRequestStatus = "recovered"
+)
+
+// checks if the status grants the requested access once resolved
+func (status AccessRequestStatus) Grants() bool {
+	return status == AccessRequestApproved || status == AccessRequestAccepted || status == OwnershipRecovered
+}
+
+// This structure is modeling a request for a user to be granted access to a repo,
+// either asked for by the user or offered by an owner through an invitation.
+type AccessRequest struct {
+	ID         string              `json:"id"`
+	RepoAuthor string              `json:"repoAuthor"`
+	RepoName   string              `json:"repoName"`
+	Kind       AccessRequestKind   `json:"kind"`
+	Requester  string              `json:"requester"` // the user filing the request or the owner sending the invitation
+	User       string              `json:"user"`      // the user who would be granted access
+	UserAccess UserAccess          `json:"userAccess"`
+	Status     AccessRequestStatus `json:"status"`
+	Timestamp  time.Time           `json:"timestamp"`
+	ResolvedBy string              `json:"resolvedBy,omitempty"`
+	ResolvedAt time.Time           `json:"resolvedAt"`
+}
+
+// helper function that creates a new pending access request
+func CreateNewAccessRequest(id string, repoAuthor string, repoName string, kind AccessRequestKind, requester string, user string, userAccess UserAccess, timestamp time.Time) (AccessRequest, error) {
+	var request AccessRequest
+
+	request.ID = id
+	request.RepoAuthor = repoAuthor
+	request.RepoName = repoName
+	request.Kind = kind
+	request.Requester = requester
+	request.User = user
+	request.UserAccess = userAccess
+	request.Status = AccessRequestPending
+	request.Timestamp = timestamp
+
+	return request, nil
+}
+
+// checks if the request still awaits a decision
+func (request *AccessRequest) IsPending() bool {
+	return request.Status == AccessRequestPending
+}
+
+// checks if the mentioned user is the one who has to decide on the request.
+// Requests are decided by members allowed to grant the requested access, invitations by the invited user.
+func (request *AccessRequest) CanBeResolvedBy(user string, repo *Repository) bool {
+	if request.Kind == AccessRequestKindInvitation {
+		return request.User == user
+	}
+	return repo.CanGrant(user, request.User, request.UserAccess)
+}
+
+// records the decision on the request
+func (request *AccessRequest) Resolve(status AccessRequestStatus, resolver string, timestamp time.Time) {
+	request.Status = status
+	request.ResolvedBy = resolver
+	request.ResolvedAt = timestamp
+}
@@ -3,11 +3,17 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"slices"
+	"sort"
 )
 
+// The branch every repo is created with, which cannot be renamed or deleted
+const DefaultBranchName = "main"
+
 // This structure is modeling a branch in the version control system
 type Branch struct {
 	Name    string            `json:"name"`
+	Head    string            `json:"head"` // hash of the commit the branch points at
 	Commits map[string]Commit `json:"commits"`
 }
 
@@ -42,32 +48,154 @@ func (branch *Branch) CommitExists(hashName string) bool {
 	return exist
 }
 
-// Checks if a commit has at least one parent in the branch.
-func (branch *Branch) ValidCommit(commit Commit) (bool, error) {
+// returns the commits of the branch as a list
+func (branch *Branch) CommitList() []Commit {
+	commits := make([]Commit, 0, len(branch.Commits))
+	for _, commit := range branch.Commits {
+		commits = append(commits, commit)
+	}
+	return commits
+}
+
+// returns the commit the branch points at
+func (branch *Branch) GetHead() (Commit, bool) {
+	head, exist := branch.Commits[branch.Head]
+	return head, exist
+}
 
-	if !branch.CommitExists(commit.Hash) {
-		if len(branch.Commits) == 0 {
-			return true, nil
+// returns the most recent commit of the branch, which was taken as its head
+// before branches stored their head
+func (branch *Branch) NewestCommit() (Commit, bool) {
+	var newest Commit
+	found := false
+	for _, commit := range branch.Commits {
+		if !found || commit.Timestamp.After(newest.Timestamp) ||
+			(commit.Timestamp.Equal(newest.Timestamp) && commit.Hash > newest.Hash) {
+			newest = commit
+			found = true
+		}
+	}
+
+	return newest, found
+}
+
+// sets the head of a branch received from a client when it does not name one.
+// The head is the only commit of the branch that is not a parent of another of its commits,
+// and every commit of the branch must be reachable from it.
+func (branch *Branch) ResolveHead() error {
+	if len(branch.Commits) == 0 {
+		branch.Head = ""
+		return nil
+	}
+
+	if branch.Head == "" {
+		isParent := make(map[string]bool)
+		for _, commit := range branch.Commits {
+			for _, hash := range commit.ParentHashes {
+				isParent[hash] = true
+			}
 		}
 
-		for _, hash := range commit.ParentHashes {
-			if branch.CommitExists(hash) {
-				timeProgressing := branch.Commits[hash].Timestamp.UnixMilli() < commit.Timestamp.UnixMilli()
-				return timeProgressing, nil
-			} else {
-				return false, errors.New("Previous commit " + hash + " not valid for branch " + branch.Name + "!")
+		for hash := range branch.Commits {
+			if isParent[hash] {
+				continue
+			}
+			if branch.Head != "" {
+				return errors.New("Branch " + branch.Name + " has more than one head!")
 			}
+			branch.Head = hash
+		}
+	}
+
+	if !branch.CommitExists(branch.Head) {
+		return errors.New("Head " + branch.Head + " is not a commit of branch " + branch.Name + "!")
+	}
+
+	reachable := GetAncestors(branch.Head, branch.Commits)
+	for hash := range branch.Commits {
+		if !reachable[hash] {
+			return errors.New("Commit " + hash + " is not reachable from the head of branch " + branch.Name + "!")
+		}
+	}
+
+	return nil
+}
+
+// Checks if a commit can be added on top of the branch.
+// knownCommits are the commits of the whole repo, by hash. Every parent of the commit must be one of them
+// and must be older than the commit, and at least one parent must be reachable from the head of the branch,
+// or be a commit of the branch added on top of it by the same push.
+// A commit without parents is only valid on an empty branch, unless allowNewRoot is set (e.g. orphan branches).
+func (branch *Branch) ValidCommit(commit Commit, knownCommits map[string]Commit, allowNewRoot bool) (bool, error) {
+
+	if branch.CommitExists(commit.Hash) {
+		return false, errors.New("Commit " + commit.Hash + " already exists in branch " + branch.Name + "!")
+	}
+
+	if len(commit.ParentHashes) == 0 {
+		if len(branch.Commits) == 0 || allowNewRoot {
+			return true, nil
+		}
+		return false, errors.New("Commit " + commit.Hash + " has no parent and would be a new root of branch " + branch.Name + "!")
+	}
+
+	for _, hash := range commit.ParentHashes {
+		parent, exist := knownCommits[hash]
+		if !exist {
+			return false, errors.New("Parent commit " + hash + " of commit " + commit.Hash + " does not exist in the repo!")
+		}
+		if parent.Timestamp.UnixMilli() >= commit.Timestamp.UnixMilli() {
+			return false, errors.New("Parent commit " + hash + " is not older than commit " + commit.Hash + "!")
+		}
+	}
+
+	head, exist := branch.GetHead()
+	if !exist {
+		return true, nil
+	}
+
+	reachable := GetAncestors(head.Hash, knownCommits)
+	for _, hash := range commit.ParentHashes {
+		if reachable[hash] || branch.CommitExists(hash) {
+			return true, nil
+		}
+	}
+
+	return false, errors.New("No parent of commit " + commit.Hash + " is reachable from the head of branch " + branch.Name + "!")
+}
+
+// returns the commits in the history of head that are not part of the branch yet, from the oldest to the newest.
+// knownCommits must hold the whole history of the head.
+func (branch *Branch) MissingCommits(head string, knownCommits map[string]Commit) ([]Commit, error) {
+	missing := make([]Commit, 0)
+	for hash := range GetAncestors(head, knownCommits) {
+		if branch.CommitExists(hash) {
+			continue
 		}
+		commit, exist := knownCommits[hash]
+		if !exist {
+			return missing, errors.New("Commit " + hash + " in the history of " + head + " does not exist!")
+		}
+		missing = append(missing, commit)
 	}
 
-	return false, errors.New("Commit " + commit.Hash + " not valid for branch " + branch.Name + "!")
+	// parents are older than their children
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].Timestamp.UnixMilli() < missing[j].Timestamp.UnixMilli()
+	})
+
+	return missing, nil
 }
 
 // Adds a Commit to the branch if it can be added according to the info avaiable to the branch.
+// The head moves to the commit when it is a child of the head or a new root.
 func (branch *Branch) AddCommit(commitLog Commit, passValidation bool) (bool, error) {
 
-	if valid, _ := branch.ValidCommit(commitLog); valid || passValidation {
+	if valid, _ := branch.ValidCommit(commitLog, branch.Commits, false); valid || passValidation {
 		branch.Commits[commitLog.Hash] = commitLog
+		if branch.Head == "" || len(commitLog.ParentHashes) == 0 || slices.Contains(commitLog.ParentHashes, branch.Head) {
+			branch.Head = commitLog.Hash
+		}
 		return true, nil
 	}
 
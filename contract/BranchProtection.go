@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"path"
+)
+
+// This structure is modeling a protection rule for the branches of a repository
+// whose name matches Pattern, either an exact branch name or a glob such as "release/*".
+type BranchProtectionRule struct {
+	Pattern              string `json:"pattern"`
+	NoDeletion           bool   `json:"noDeletion"`
+	NoRename             bool   `json:"noRename"`
+	OwnerOnlyPush        bool   `json:"ownerOnlyPush"`
+	RequireSignedCommits bool   `json:"requireSignedCommits"`
+	RequireLinearHistory bool   `json:"requireLinearHistory"`
+
+	// MSP IDs of the organizations whose peers must also endorse writes to matching branches
+	EndorsingOrgs []string `json:"endorsingOrgs,omitempty"`
+}
+
+// This function takes a json string that represents the marshalling of BranchProtectionRule
+// and returns a BranchProtectionRule.
+func UnmarshalBranchProtectionRule(objectString string) (BranchProtectionRule, error) {
+	var rule BranchProtectionRule
+
+	err := json.Unmarshal([]byte(objectString), &rule)
+	if err != nil {
+		return rule, err
+	}
+
+	if _, err := path.Match(rule.Pattern, ""); err != nil || rule.Pattern == "" {
+		return rule, errors.New("Invalid branch pattern " + rule.Pattern + "!")
+	}
+
+	return rule, nil
+}
+
+// checks if the rule applies to the mentioned branch
+func (rule *BranchProtectionRule) Matches(branchName string) bool {
+	if rule.Pattern == branchName {
+		return true
+	}
+	matched, _ := path.Match(rule.Pattern, branchName)
+	return matched
+}
+
+// returns an error naming the rule and the option that rejected an operation
+func (rule *BranchProtectionRule) violation(branchName string, option string) error {
+	return errors.New("Branch " + branchName + " is protected by rule '" + rule.Pattern + "': " + option)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRepositoryCheckBranchPush(t *testing.T) {
+	unsigned := testCommit("c", 2, "b")
+	signed := testCommit("c", 2, "b")
+	signed.Verified = true
+	merge := testCommit("m", 3, "b", "side")
+	merge.Verified = true
+
+	tests := []struct {
+		name    string
+		rule    BranchProtectionRule
+		branch  string
+		user    string
+		commits []Commit
+		allowed bool
+	}{
+		{"unprotected branch", BranchProtectionRule{Pattern: "release/*", OwnerOnlyPush: true}, "main", "bob", []Commit{unsigned}, true},
+		{"owner-only push by a maintainer", BranchProtectionRule{Pattern: "main", OwnerOnlyPush: true}, "main", "bob", []Commit{signed}, false},
+		{"owner-only push by the owner", BranchProtectionRule{Pattern: "main", OwnerOnlyPush: true}, "main", "alice", []Commit{unsigned}, true},
+		{"owner-only glob", BranchProtectionRule{Pattern: "release/*", OwnerOnlyPush: true}, "release/1.0", "bob", []Commit{signed}, false},
+		{"unsigned commit", BranchProtectionRule{Pattern: "main", RequireSignedCommits: true}, "main", "alice", []Commit{signed, unsigned}, false},
+		{"signed commits", BranchProtectionRule{Pattern: "main", RequireSignedCommits: true}, "main", "bob", []Commit{signed}, true},
+		{"merge commit with linear history", BranchProtectionRule{Pattern: "main", RequireLinearHistory: true}, "main", "alice", []Commit{merge}, false},
+		{"no commits", BranchProtectionRule{Pattern: "main", RequireSignedCommits: true}, "main", "bob", []Commit{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := testRepo()
+			repo.UpdateAccess("bob", MaintainAccess, "alice", testEpoch, time.Time{})
+			repo.SetBranchProtection(test.rule)
+
+			err := repo.CheckBranchPush(test.branch, test.user, test.commits, false)
+			if allowed := err == nil; allowed != test.allowed {
+				t.Fatalf("CheckBranchPush() = %v, want allowed %v", err, test.allowed)
+			}
+		})
+	}
+}
+
+func TestRepositoryCanProtectBranch(t *testing.T) {
+	repo := testRepo()
+	repo.UpdateAccess("admin", AdminAccess, "alice", testEpoch, time.Time{})
+	repo.UpdateAccess("bob", MaintainAccess, "alice", testEpoch, time.Time{})
+	repo.SetBranchProtection(BranchProtectionRule{Pattern: "main", OwnerOnlyPush: true})
+	repo.SetBranchProtection(BranchProtectionRule{Pattern: "dev", NoDeletion: true})
+
+	tests := []struct {
+		name    string
+		user    string
+		rule    BranchProtectionRule
+		allowed bool
+	}{
+		{"admin sets a rule", "admin", BranchProtectionRule{Pattern: "dev", NoRename: true}, true},
+		{"maintainer sets a rule", "bob", BranchProtectionRule{Pattern: "dev", NoRename: true}, false},
+		{"admin adds an owner-only rule", "admin", BranchProtectionRule{Pattern: "release/*", OwnerOnlyPush: true}, false},
+		{"admin relaxes an owner-only rule", "admin", BranchProtectionRule{Pattern: "main", NoDeletion: true}, false},
+		{"admin removes an owner-only rule", "admin", repo.BranchProtections["main"], false},
+		{"owner removes an owner-only rule", "alice", repo.BranchProtections["main"], true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if allowed := repo.CanProtectBranch(test.user, test.rule); allowed != test.allowed {
+				t.Fatalf("CanProtectBranch() = %v, want %v", allowed, test.allowed)
+			}
+		})
+	}
+}
+
+func TestRepositoryCheckBranchPushRequiredApprovals(t *testing.T) {
+	repo := testRepo()
+	repo.RequiredApprovals = 1
+	repo.SetBranchProtection(BranchProtectionRule{Pattern: "main", NoDeletion: true})
+	commits := []Commit{testCommit("c", 2, "b")}
+
+	// owners included, protected branches only change through approved pull requests
+	if err := repo.CheckBranchPush("main", "alice", commits, false); err == nil {
+		t.Fatalf("direct push to a protected branch accepted")
+	}
+	if err := repo.CheckBranchPush("main", "alice", nil, false); err == nil {
+		t.Fatalf("force-push without new commits to a protected branch accepted")
+	}
+	if err := repo.CheckBranchPush("main", "alice", commits, true); err != nil {
+		t.Fatalf("merge of an approved pull request refused: %v", err)
+	}
+	if err := repo.CheckBranchPush("feature", "alice", commits, false); err != nil {
+		t.Fatalf("direct push to an unprotected branch refused: %v", err)
+	}
+
+	repo.RequiredApprovals = 0
+	if err := repo.CheckBranchPush("main", "alice", commits, false); err != nil {
+		t.Fatalf("direct push refused without required approvals: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+var testEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// returns a commit made the given number of minutes after testEpoch
+func testCommit(hash string, minute int, parents ...string) Commit {
+	commit, _ := CreateNewCommit("commit "+hash, "alice", "", hash, testEpoch.Add(time.Duration(minute)*time.Minute), parents, nil)
+	return commit
+}
+
+// returns a branch holding the commits, its head being the last one
+func testBranch(name string, commits ...Commit) Branch {
+	branch, _ := CreateNewBranch(name, nil)
+	for _, commit := range commits {
+		branch.Commits[commit.Hash] = commit
+		branch.Head = commit.Hash
+	}
+	return branch
+}
+
+func TestBranchValidCommit(t *testing.T) {
+	a := testCommit("a", 0)
+	b := testCommit("b", 1, "a")
+	side := testCommit("side", 1, "a")
+	knownCommits := map[string]Commit{"a": a, "b": b, "side": side}
+
+	tests := []struct {
+		name         string
+		branch       Branch
+		commit       Commit
+		allowNewRoot bool
+		valid        bool
+	}{
+		{"root of an empty branch", testBranch("main"), testCommit("a", 0), false, true},
+		{"dangling parent in an empty branch", testBranch("main"), testCommit("c", 5, "missing"), false, false},
+		{"known parent in an empty branch", testBranch("main"), testCommit("c", 5, "b"), false, true},
+		{"second root", testBranch("main", a, b), testCommit("root", 5), false, false},
+		{"second root allowed", testBranch("main", a, b), testCommit("root", 5), true, true},
+		{"existing commit", testBranch("main", a, b), b, false, false},
+		{"child of the head", testBranch("main", a, b), testCommit("c", 2, "b"), false, true},
+		{"child of an ancestor of the head", testBranch("main", a, b), testCommit("c", 2, "a"), false, true},
+		{"child of an unreachable commit", testBranch("main", a, b), testCommit("c", 2, "side"), false, false},
+		{"unknown parent", testBranch("main", a, b), testCommit("c", 2, "b", "missing"), false, false},
+		{"parent not older", testBranch("main", a, b), testCommit("c", 1, "b"), false, false},
+		{"merge of the head and an unreachable commit", testBranch("main", a, b), testCommit("c", 2, "side", "b"), false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			valid, err := test.branch.ValidCommit(test.commit, knownCommits, test.allowNewRoot)
+			if valid != test.valid {
+				t.Fatalf("ValidCommit() = %v, %v, want %v", valid, err, test.valid)
+			}
+			if !valid && err == nil {
+				t.Fatalf("ValidCommit() rejected the commit without an error")
+			}
+		})
+	}
+}
+
+func TestBranchMissingCommits(t *testing.T) {
+	a := testCommit("a", 0)
+	b := testCommit("b", 1, "a")
+	c := testCommit("c", 2, "b")
+	x := testCommit("x", 2, "b")
+	m := testCommit("m", 3, "c", "x")
+	knownCommits := map[string]Commit{"a": a, "b": b, "c": c, "x": x, "m": m}
+
+	tests := []struct {
+		name    string
+		branch  Branch
+		head    string
+		missing []string // hashes from the oldest to the newest, nil if the history is incomplete
+	}{
+		{"head already in the branch", testBranch("main", a, b), "b", []string{}},
+		{"descendant of the head", testBranch("main", a, b), "c", []string{"c"}},
+		{"merge of the head", testBranch("main", a, b, c), "m", []string{"x", "m"}},
+		{"whole history for an empty branch", testBranch("main"), "c", []string{"a", "b", "c"}},
+		{"unknown head", testBranch("main", a, b), "missing", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			missing, err := test.branch.MissingCommits(test.head, knownCommits)
+			if test.missing == nil {
+				if err == nil {
+					t.Fatalf("MissingCommits() = %v, want an error", missing)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MissingCommits() = %v", err)
+			}
+
+			hashes := make([]string, 0)
+			for _, commit := range missing {
+				hashes = append(hashes, commit.Hash)
+			}
+			if !slices.Equal(hashes, test.missing) {
+				t.Fatalf("MissingCommits() = %v, want %v", hashes, test.missing)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+// This enum represents an action on a repo that a role may or may not be allowed to perform
+type Capability string
+
+const (
+	CapabilityRead              Capability = "read"              // clone and query the repo
+	CapabilityManageMetadata    Capability = "manageMetadata"    // triage access requests and other repo metadata
+	CapabilityPush              Capability = "push"              // create and push to branches other than the default branch
+	CapabilityPushDefaultBranch Capability = "pushDefaultBranch" // push to the default branch
+	CapabilityManageBranches    Capability = "manageBranches"    // rename and delete branches
+	CapabilityManageTags        Capability = "manageTags"        // create and delete tags
+	CapabilityRecoverOwnership  Capability = "recoverOwnership"  // propose and approve ownership recoveries
+	CapabilityManageAccess      Capability = "manageAccess"      // grant and revoke access, decide on access requests
+	CapabilityManageSettings    Capability = "manageSettings"    // change visibility, branch protections and other settings
+	CapabilityAdminister        Capability = "administer"        // rename, delete and transfer the repo, manage owners
+)
+
+// The capabilities granted by each role.
+// Each role is granted the capabilities of the roles below it, except for ownership recovery
+// that only members who are not owners take part in.
+var roleCapabilities = map[UserAccess][]Capability{
+	ReadAccess:      {CapabilityRead},
+	TriageAccess:    {CapabilityRead, CapabilityManageMetadata},
+	WriteAccess:     {CapabilityRead, CapabilityManageMetadata, CapabilityPush},
+	ReadWriteAccess: {CapabilityRead, CapabilityManageMetadata, CapabilityPush, CapabilityPushDefaultBranch, CapabilityManageBranches, CapabilityManageTags, CapabilityRecoverOwnership},
+	AdminAccess: {CapabilityRead, CapabilityManageMetadata, CapabilityPush, CapabilityPushDefaultBranch, CapabilityManageBranches, CapabilityManageTags, CapabilityRecoverOwnership,
+		CapabilityManageAccess, CapabilityManageSettings},
+	OwnerAccess: {CapabilityRead, CapabilityManageMetadata, CapabilityPush, CapabilityPushDefaultBranch, CapabilityManageBranches, CapabilityManageTags,
+		CapabilityManageAccess, CapabilityManageSettings, CapabilityAdminister},
+}
+
+// checks if the role grants the capability
+func (access UserAccess) HasCapability(capability Capability) bool {
+	for _, granted := range roleCapabilities[access] {
+		if granted == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the capabilities granted by the role
+func (access UserAccess) Capabilities() []Capability {
+	capabilities := make([]Capability, len(roleCapabilities[access]))
+	copy(capabilities, roleCapabilities[access])
+	return capabilities
+}
+
+// returns the capability needed to push to the mentioned branch
+func PushCapability(branchName string) Capability {
+	if branchName == DefaultBranchName {
+		return CapabilityPushDefaultBranch
+	}
+	return CapabilityPush
+}
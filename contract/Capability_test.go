@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserAccessHasCapability(t *testing.T) {
+	// the lowest role granted each capability
+	lowestRoles := map[Capability]UserAccess{
+		CapabilityRead:              ReadAccess,
+		CapabilityManageMetadata:    TriageAccess,
+		CapabilityPush:              WriteAccess,
+		CapabilityPushDefaultBranch: ReadWriteAccess,
+		CapabilityManageBranches:    ReadWriteAccess,
+		CapabilityManageTags:        ReadWriteAccess,
+		CapabilityManageAccess:      AdminAccess,
+		CapabilityManageSettings:    AdminAccess,
+		CapabilityAdminister:        OwnerAccess,
+	}
+	roles := []UserAccess{NoAccess, ReadAccess, TriageAccess, WriteAccess, ReadWriteAccess, AdminAccess, OwnerAccess}
+
+	for capability, lowestRole := range lowestRoles {
+		for _, role := range roles {
+			want := role.Level() >= lowestRole.Level()
+			if granted := role.HasCapability(capability); granted != want {
+				t.Errorf("%v.HasCapability(%s) = %v, want %v", role, capability, granted, want)
+			}
+		}
+	}
+
+	// owners do not take part in ownership recoveries
+	for _, role := range roles {
+		want := role == ReadWriteAccess || role == AdminAccess
+		if granted := role.HasCapability(CapabilityRecoverOwnership); granted != want {
+			t.Errorf("%v.HasCapability(%s) = %v, want %v", role, CapabilityRecoverOwnership, granted, want)
+		}
+	}
+}
+
+func TestRepositoryCanGrant(t *testing.T) {
+	repo := testRepo()
+	repo.UpdateAccess("admin", AdminAccess, "alice", testEpoch, time.Time{})
+	repo.UpdateAccess("bob", MaintainAccess, "alice", testEpoch, time.Time{})
+	repo.UpdateAccess("olga", OwnerAccess, "alice", testEpoch, time.Time{})
+
+	tests := []struct {
+		authorizer string
+		authorized string
+		access     UserAccess
+		allowed    bool
+	}{
+		{"admin", "carol", WriteAccess, true},
+		{"admin", "bob", AdminAccess, true},
+		{"admin", "carol", OwnerAccess, false}, // only owners grant ownership
+		{"admin", "olga", ReadAccess, false},   // or revoke it
+		{"bob", "carol", ReadAccess, false},    // maintainers do not manage access
+		{"alice", "olga", ReadAccess, true},
+		{"alice", "carol", OwnerAccess, true},
+	}
+
+	for _, test := range tests {
+		if allowed := repo.CanGrant(test.authorizer, test.authorized, test.access); allowed != test.allowed {
+			t.Errorf("CanGrant(%s, %s, %v) = %v, want %v", test.authorizer, test.authorized, test.access, allowed, test.allowed)
+		}
+	}
+}
+
+func TestPushCapability(t *testing.T) {
+	repo := testRepo()
+	repo.UpdateAccess("writer", WriteAccess, "alice", testEpoch, time.Time{})
+
+	if repo.CanPush("writer", DefaultBranchName) {
+		t.Fatalf("writer can push to the default branch")
+	}
+	if !repo.CanPush("writer", "feature") {
+		t.Fatalf("writer cannot push to another branch")
+	}
+}
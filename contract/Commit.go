@@ -1,6 +1,9 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"time"
 )
 
@@ -8,13 +11,18 @@ import (
 // including data normally stored through git and data required to get the git
 // objects through the IPFS Cluster.
 type Commit struct {
-	Hash          string            `json:"hash"`
-	Author        string            `json:"author"`
-	AuthorEmail   string            `json:"authorEmail"`
-	Message       string            `json:"message"`
-	ParentHashes  []string          `json:"parentHashes"`
-	Timestamp     time.Time         `json:"timestamp"`
-	StorageHashes map[string]string `json:"storageHashes"`
+	Hash            string            `json:"hash"`
+	Author          string            `json:"author"`
+	AuthorEmail     string            `json:"authorEmail,omitempty"` // git author email, kept in the collection of personal data rather than in the commit document
+	AuthorID        string            `json:"authorID,omitempty"`    // the registered user who pushed the commit
+	Message         string            `json:"message"`
+	ParentHashes    []string          `json:"parentHashes"`
+	Timestamp       time.Time         `json:"timestamp"`
+	StorageHashes   map[string]string `json:"storageHashes"`
+	Signature       string            `json:"signature,omitempty"`
+	SignatureFormat string            `json:"signatureFormat,omitempty"`
+	Verified        bool              `json:"verified"`
+	KeyEpoch        int               `json:"keyEpoch,omitempty"` // key epoch of the repo the stored files are encrypted under
 }
 
 // this is a helper function to initialize a new commit object instance
@@ -31,3 +39,92 @@ func CreateNewCommit(message string, author string, email string, hash string, t
 
 	return log, nil
 }
+
+// returns the canonical serialization of the commit that is covered by its signature.
+// It is the compact JSON object with sorted keys
+// {"hash", "parentHashes", "storageHashes", "timestamp"} where timestamp is in unix milliseconds.
+func (commit *Commit) SigningPayload() []byte {
+	parentHashes := commit.ParentHashes
+	if parentHashes == nil {
+		parentHashes = []string{}
+	}
+	storageHashes := commit.StorageHashes
+	if storageHashes == nil {
+		storageHashes = map[string]string{}
+	}
+
+	data := map[string]interface{}{"hash": commit.Hash, "parentHashes": parentHashes, "storageHashes": storageHashes, "timestamp": commit.Timestamp.UnixMilli()}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(data)
+
+	return bytes.TrimRight(buffer.Bytes(), "\n")
+}
+
+// checks if the commit carries a signature
+func (commit *Commit) IsSigned() bool {
+	return commit.Signature != ""
+}
+
+// verifies the commit signature against the keys out of keys that were valid at the commit's
+// timestamp and records the outcome.
+// Unsigned commits are left unverified without error.
+func (commit *Commit) VerifySignature(keys []UserKey) error {
+	commit.Verified = false
+
+	if !commit.IsSigned() {
+		return nil
+	}
+
+	validKeys, err := UserKeysValidAt(keys, commit.Timestamp)
+	if err != nil {
+		return errors.New("Signature of commit " + commit.Hash + " is not valid: " + err.Error())
+	}
+
+	_, err = VerifySignatureWithAnyKey(validKeys, commit.SigningPayload(), commit.Signature, commit.SignatureFormat)
+	if err != nil {
+		return errors.New("Signature of commit " + commit.Hash + " is not valid: " + err.Error())
+	}
+
+	commit.Verified = true
+	return nil
+}
+
+// verifies the signature of a commit new to the ledger, which must have been made with a key valid both at the
+// commit's timestamp and at pushedAt. The timestamp comes from the client, so a revoked key could otherwise
+// still sign commits dated back to when it was valid.
+func (commit *Commit) VerifyPushedSignature(keys []UserKey, pushedAt time.Time) error {
+	if commit.IsSigned() {
+		pushKeys, err := UserKeysValidAt(keys, pushedAt)
+		if err != nil {
+			commit.Verified = false
+			return errors.New("Signature of commit " + commit.Hash + " is not valid: " + err.Error())
+		}
+		keys = pushKeys
+	}
+
+	return commit.VerifySignature(keys)
+}
+
+// returns the hashes of the commit and of all of its ancestors among the given commits
+func GetAncestors(commitHash string, commits map[string]Commit) map[string]bool {
+	ancestors := make(map[string]bool)
+	toVisit := []string{commitHash}
+
+	for len(toVisit) > 0 {
+		hash := toVisit[len(toVisit)-1]
+		toVisit = toVisit[:len(toVisit)-1]
+		if ancestors[hash] {
+			continue
+		}
+		ancestors[hash] = true
+
+		if commit, exist := commits[hash]; exist {
+			toVisit = append(toVisit, commit.ParentHashes...)
+		}
+	}
+
+	return ancestors
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCommitVerifySignature(t *testing.T) {
+	signer := newEd25519TestSigner(t)
+	key, err := CreateNewUserKey("alice", DefaultUserKeyLabel, signer.publicKeyPEM, testEpoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []UserKey{key}
+
+	signed := testCommit("a", 1)
+	signed.Signature = signer.sign(signed.SigningPayload())
+	if err := signed.VerifySignature(keys); err != nil || !signed.Verified {
+		t.Fatalf("VerifySignature() = %v, verified %v, want a verified commit", err, signed.Verified)
+	}
+
+	// the signature covers the parents of the commit
+	tampered := signed
+	tampered.ParentHashes = []string{"other"}
+	if err := tampered.VerifySignature(keys); err == nil || tampered.Verified {
+		t.Fatalf("signature accepted for a commit with other parents")
+	}
+
+	unsigned := testCommit("b", 1)
+	unsigned.Verified = true
+	if err := unsigned.VerifySignature(keys); err != nil || unsigned.Verified {
+		t.Fatalf("VerifySignature() = %v, verified %v, want an unverified commit without error", err, unsigned.Verified)
+	}
+
+	if err := signed.VerifySignature(nil); err == nil {
+		t.Fatalf("signature accepted without any key of the pusher")
+	}
+}
+
+func TestRepositoryVerifyCommits(t *testing.T) {
+	signer := newECDSATestSigner(t)
+	key, _ := CreateNewUserKey("alice", DefaultUserKeyLabel, signer.publicKeyPEM, testEpoch)
+
+	signed := testCommit("a", 1)
+	signed.Signature = signer.sign(signed.SigningPayload())
+	unsigned := testCommit("b", 2, "a")
+
+	repo := testRepo()
+	commits, err := repo.VerifyCommits([]Commit{signed, unsigned}, "alice", []UserKey{key})
+	if err != nil {
+		t.Fatalf("VerifyCommits() = %v", err)
+	}
+	if !commits[0].Verified || commits[1].Verified {
+		t.Fatalf("verified = %v, %v, want only the signed commit verified", commits[0].Verified, commits[1].Verified)
+	}
+	if commits[0].AuthorID != "alice" || commits[1].AuthorID != "alice" {
+		t.Fatalf("commits are not attributed to their pusher")
+	}
+
+	repo.RequireSignedCommits = true
+	if _, err := repo.VerifyCommits([]Commit{signed, unsigned}, "alice", []UserKey{key}); err == nil {
+		t.Fatalf("unsigned commit accepted by a repo requiring signed commits")
+	}
+}
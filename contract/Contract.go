@@ -24,9 +24,11 @@ func (contract *Contract) Invoke(stub shim.ChaincodeStubInterface) peer.Response
 	function, args := stub.GetFunctionAndParameters()
 
 	fmt.Println("****************************************\nStarting invocation .. \nfunctionName:\t"+function+"\nargs:\t\n", args)
-	defer fmt.Println("Invocation end\n")
+	defer fmt.Print("Invocation end\n\n")
 
-	if function == "logIn" {
+	if function == "requestLoginChallenge" {
+		return contract.requestLoginChallenge(stub, args)
+	} else if function == "logIn" {
 		return contract.logIn(stub, args)
 	} else if function == "logOut" {
 		return contract.logOut(stub)
@@ -36,6 +38,10 @@ func (contract *Contract) Invoke(stub shim.ChaincodeStubInterface) peer.Response
 		return contract.registerNewUser(stub, args)
 	} else if function == "changePublicKey" {
 		return contract.changePublicKey(stub, args)
+	} else if function == "addUserKey" {
+		return contract.addUserKey(stub, args)
+	} else if function == "removeUserKey" {
+		return contract.removeUserKey(stub, args)
 	} else if function == "addNewRepo" {
 		return contract.addNewRepo(stub, args)
 	} else if function == "queryRepo" {
@@ -48,6 +54,8 @@ func (contract *Contract) Invoke(stub shim.ChaincodeStubInterface) peer.Response
 		return contract.clone(stub, args)
 	} else if function == "addNewBranch" {
 		return contract.addNewBranch(stub, args)
+	} else if function == "addNewRootBranch" {
+		return contract.addNewRootBranch(stub, args)
 	} else if function == "renameBranch" {
 		return contract.renameBranch(stub, args)
 	} else if function == "deleteBranch" {
@@ -60,6 +68,34 @@ func (contract *Contract) Invoke(stub shim.ChaincodeStubInterface) peer.Response
 		return contract.pushOneCommit(stub, args)
 	} else if function == "pushMultiple" {
 		return contract.pushMultipleCommits(stub, args)
+	} else if function == "pushNewRoot" {
+		return contract.pushNewRootCommits(stub, args)
+	} else if function == "forcePushBranch" {
+		return contract.forcePushBranch(stub, args)
+	} else if function == "restoreBranchHead" {
+		return contract.restoreBranchHead(stub, args)
+	} else if function == "queryReflog" {
+		return contract.queryReflog(stub, args)
+	} else if function == "mergeBranch" {
+		return contract.mergeBranch(stub, args)
+	} else if function == "openPullRequest" {
+		return contract.openPullRequest(stub, args)
+	} else if function == "updatePullRequest" {
+		return contract.updatePullRequest(stub, args)
+	} else if function == "closePullRequest" {
+		return contract.closePullRequest(stub, args)
+	} else if function == "mergePullRequest" {
+		return contract.mergePullRequest(stub, args)
+	} else if function == "queryRepoPullRequests" {
+		return contract.queryRepoPullRequests(stub, args)
+	} else if function == "queryUserPullRequests" {
+		return contract.queryUserPullRequests(stub, args)
+	} else if function == "submitReview" {
+		return contract.submitReview(stub, args)
+	} else if function == "queryPullRequestReviews" {
+		return contract.queryPullRequestReviews(stub, args)
+	} else if function == "queryTags" {
+		return contract.queryTags(stub, args)
 	} else if function == "pull" {
 		return contract.queryBranchCommitsAfter(stub, args)
 	} else if function == "checkoutLast" {
@@ -68,10 +104,82 @@ func (contract *Contract) Invoke(stub shim.ChaincodeStubInterface) peer.Response
 		return contract.queryUser(stub, args)
 	} else if function == "queryUsers" {
 		return contract.queryUsers(stub, args)
+	} else if function == "queryUserKeys" {
+		return contract.queryUserKeys(stub, args)
 	} else if function == "updateRepoUserAccess" {
 		return contract.updateRepoUserAccess(stub, args)
 	} else if function == "queryRepoUserAccess" {
 		return contract.queryRepoUserAccess(stub, args)
+	} else if function == "queryEffectiveRepoAccess" {
+		return contract.queryEffectiveRepoAccess(stub, args)
+	} else if function == "updateRepoRequireSignedCommits" {
+		return contract.updateRepoRequireSignedCommits(stub, args)
+	} else if function == "createTeam" {
+		return contract.createTeam(stub, args)
+	} else if function == "addTeamMember" {
+		return contract.addTeamMember(stub, args)
+	} else if function == "removeTeamMember" {
+		return contract.removeTeamMember(stub, args)
+	} else if function == "queryTeam" {
+		return contract.queryTeam(stub, args)
+	} else if function == "setBranchProtection" {
+		return contract.setBranchProtection(stub, args)
+	} else if function == "removeBranchProtection" {
+		return contract.removeBranchProtection(stub, args)
+	} else if function == "updateRepoVisibility" {
+		return contract.updateRepoVisibility(stub, args)
+	} else if function == "requestRepoAccess" {
+		return contract.requestRepoAccess(stub, args)
+	} else if function == "approveAccessRequest" {
+		return contract.approveAccessRequest(stub, args)
+	} else if function == "denyAccessRequest" {
+		return contract.denyAccessRequest(stub, args)
+	} else if function == "inviteUser" {
+		return contract.inviteUser(stub, args)
+	} else if function == "acceptInvitation" {
+		return contract.acceptInvitation(stub, args)
+	} else if function == "declineInvitation" {
+		return contract.declineInvitation(stub, args)
+	} else if function == "queryRepoAccessRequests" {
+		return contract.queryRepoAccessRequests(stub, args)
+	} else if function == "queryUserAccessRequests" {
+		return contract.queryUserAccessRequests(stub, args)
+	} else if function == "transferRepo" {
+		return contract.transferRepo(stub, args)
+	} else if function == "acceptRepoTransfer" {
+		return contract.acceptRepoTransfer(stub, args)
+	} else if function == "declineRepoTransfer" {
+		return contract.declineRepoTransfer(stub, args)
+	} else if function == "updateRepoRecoveryQuorum" {
+		return contract.updateRepoRecoveryQuorum(stub, args)
+	} else if function == "updateRepoRequiredApprovals" {
+		return contract.updateRepoRequiredApprovals(stub, args)
+	} else if function == "createTag" {
+		return contract.createTag(stub, args)
+	} else if function == "deleteTag" {
+		return contract.deleteTag(stub, args)
+	} else if function == "setTagProtection" {
+		return contract.setTagProtection(stub, args)
+	} else if function == "removeTagProtection" {
+		return contract.removeTagProtection(stub, args)
+	} else if function == "proposeOwnershipRecovery" {
+		return contract.proposeOwnershipRecovery(stub, args)
+	} else if function == "approveOwnershipRecovery" {
+		return contract.approveOwnershipRecovery(stub, args)
+	} else if function == "cancelOwnershipRecovery" {
+		return contract.cancelOwnershipRecovery(stub, args)
+	} else if function == "queryOwnershipRecoveries" {
+		return contract.queryOwnershipRecoveries(stub, args)
+	} else if function == "updateRepoEndorsementPolicy" {
+		return contract.updateRepoEndorsementPolicy(stub, args)
+	} else if function == "eraseUserData" {
+		return contract.eraseUserData(stub, args)
+	} else if function == "distributeRepoKey" {
+		return contract.distributeRepoKey(stub, args)
+	} else if function == "enableRepoEncryption" {
+		return contract.enableRepoEncryption(stub, args)
+	} else if function == "queryRepoKeyEnvelope" {
+		return contract.queryRepoKeyEnvelope(stub, args)
 	}
 
 	return shim.Error("Invalid Smart Contract function name.")
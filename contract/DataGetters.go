@@ -7,33 +7,287 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-protos-go/peer"
 )
 
+// resolves the Fabric client identity that submitted the current transaction
+func getCallerIdentity(stub shim.ChaincodeStubInterface) (UserIdentity, error) {
+	var identity UserIdentity
+
+	clientID, err := cid.New(stub)
+	if err != nil {
+		return identity, errors.New("Could not read the client identity of the transaction!")
+	}
+
+	identity.MSPID, err = clientID.GetMSPID()
+	if err != nil {
+		return identity, errors.New("Could not read the MSP ID of the transaction creator!")
+	}
+
+	identity.ID, err = clientID.GetID()
+	if err != nil {
+		return identity, errors.New("Could not read the ID of the transaction creator!")
+	}
+
+	return identity, nil
+}
+
+// returns the user bound to the provided client identity and the fingerprint of the key they logged in with,
+// whether that key is still valid or not
+func (contract *Contract) getIdentityBinding(stub shim.ChaincodeStubInterface, identity UserIdentity) (string, string, error) {
+	identityKey, _ := getIdentityKey(stub, identity)
+	identityData, err := stub.GetState(identityKey)
+	if err != nil || len(identityData) == 0 {
+		return "", "", errors.New("No user is logged in!")
+	}
+
+	structuredIdentityData := map[string]string{}
+	err = json.Unmarshal(identityData, &structuredIdentityData)
+	if err != nil {
+		return "", "", errors.New("Invalid connected user information!")
+	}
+
+	return structuredIdentityData["name"], structuredIdentityData["keyFingerprint"], nil
+}
+
+// returns the name of the user bound to the provided client identity, if any.
+// The binding only holds while the key the user logged in with is valid
+func (contract *Contract) getIdentityUserName(stub shim.ChaincodeStubInterface, identity UserIdentity) (string, error) {
+	userName, keyFingerprint, err := contract.getIdentityBinding(stub, identity)
+	if err != nil {
+		return "", err
+	}
+
+	userPublicInfo, failMessage := contract.getUserPublicInfo(stub, userName)
+	if failMessage.Message != "" {
+		return "", errors.New("Invalid connected user information!")
+	}
+
+	userKeys, err := contract.getUserKeys(stub, userPublicInfo)
+	if err != nil {
+		return "", err
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+	for _, key := range userKeys {
+		if key.Fingerprint == keyFingerprint && key.ValidAt(currentTime.AsTime()) {
+			return userName, nil
+		}
+	}
+
+	return "", errors.New("The key user " + userName + " logged in with is no longer valid. Please log in again!")
+}
+
+// returns the user that is logged in with the client identity of the current transaction
 func (contract *Contract) getLoggedInUser(stub shim.ChaincodeStubInterface) (UserPublicInfo, error) {
 	var loggedInUserInfo UserPublicInfo
 
-	loggedInUserMarshaled, err := stub.GetState("loggedInUser")
+	identity, err := getCallerIdentity(stub)
 	if err != nil {
-		return loggedInUserInfo, errors.New("No user is logged in!")
+		return loggedInUserInfo, err
 	}
 
-	err = json.Unmarshal(loggedInUserMarshaled, &loggedInUserInfo)
+	userName, err := contract.getIdentityUserName(stub, identity)
 	if err != nil {
+		return loggedInUserInfo, err
+	}
+
+	loggedInUserInfo, failMessage := contract.getUserPublicInfo(stub, userName)
+	if failMessage.Message != "" {
 		return loggedInUserInfo, errors.New("Invalid connected user information!")
 	}
 
 	return loggedInUserInfo, nil
 }
 
+// fetches a login challenge previously issued to the user
+func (contract *Contract) getLoginChallenge(stub shim.ChaincodeStubInterface, name string, challengeID string) (LoginChallenge, error) {
+	var challenge LoginChallenge
+
+	challengeKey, _ := getLoginChallengeKey(stub, name, challengeID)
+	challengeData, err := stub.GetState(challengeKey)
+	if err != nil || len(challengeData) == 0 {
+		return challenge, errors.New("Login challenge does not exist!")
+	}
+
+	structuredChallengeData := map[string]string{}
+	err = json.Unmarshal(challengeData, &structuredChallengeData)
+	if err != nil {
+		return challenge, errors.New("Could not unmarshal login challenge!")
+	}
+
+	challenge.ChallengeID = structuredChallengeData["challengeID"]
+	challenge.Name = structuredChallengeData["name"]
+	challenge.Nonce = structuredChallengeData["nonce"]
+	_ = json.Unmarshal([]byte(structuredChallengeData["identity"]), &challenge.Identity)
+	challenge.Timestamp, _ = time.Parse(time.RFC3339Nano, structuredChallengeData["timestamp"])
+	challenge.Used, _ = strconv.ParseBool(structuredChallengeData["used"])
+
+	return challenge, nil
+}
+
 func (contract *Contract) whoAmI(stub shim.ChaincodeStubInterface) peer.Response {
-	loggedInUserMarshaled, err := stub.GetState("loggedInUser")
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// users read their own email back, other lookups of users leave it out
+	loggedInUser.Email = contract.getUserEmail(stub, loggedInUser.Name)
+
+	serialized, _ := json.Marshal(loggedInUser)
+	return shim.Success(serialized)
+}
+
+func (contract *Contract) getOrganization(stub shim.ChaincodeStubInterface, name string) (Organization, error) {
+	var organization Organization
+
+	organizationKey, _ := getOrganizationKey(stub, name)
+	organizationData, err := stub.GetState(organizationKey)
+	if err != nil || len(organizationData) == 0 {
+		return organization, errors.New("Organization " + name + " does not exist")
+	}
+
+	structuredOrganizationData := map[string]string{}
+	err = json.Unmarshal(organizationData, &structuredOrganizationData)
+	if err != nil {
+		return organization, errors.New("Could not unmarshal organization " + name)
+	}
+
+	organization.Name = structuredOrganizationData["name"]
+	_ = json.Unmarshal([]byte(structuredOrganizationData["owners"]), &organization.Owners)
+	if organization.Owners == nil {
+		organization.Owners = make(map[string]bool)
+	}
+
+	return organization, nil
+}
+
+func (contract *Contract) getTeam(stub shim.ChaincodeStubInterface, organization string, name string) (Team, error) {
+	var team Team
+
+	teamKey, _ := getTeamKey(stub, organization, name)
+	teamData, err := stub.GetState(teamKey)
+	if err != nil || len(teamData) == 0 {
+		return team, errors.New("Team " + TeamPrincipal(organization, name) + " does not exist")
+	}
+
+	structuredTeamData := map[string]string{}
+	err = json.Unmarshal(teamData, &structuredTeamData)
+	if err != nil {
+		return team, errors.New("Could not unmarshal team " + TeamPrincipal(organization, name))
+	}
+
+	team, _ = CreateNewTeam(structuredTeamData["organization"], structuredTeamData["name"])
+	_ = json.Unmarshal([]byte(structuredTeamData["members"]), &team.Members)
+	if team.Members == nil {
+		team.Members = make(map[string]bool)
+	}
+
+	return team, nil
+}
+
+func (contract *Contract) queryTeam(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// organization, teamName
+
+	fmt.Println("Querying the ledger .. queryTeam", args)
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2.")
+	}
+
+	team, err := contract.getTeam(stub, args[0], args[1])
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
-	return shim.Success(loggedInUserMarshaled)
+	serialized, _ := json.Marshal(team)
+	return shim.Success(serialized)
+}
+
+// The maximum number of redirects followed when a repo has been transferred several times
+const maxRepoRedirects = 10
+
+// returns the author and name under which a repo is currently stored, following the redirect
+// records left behind when repos are transferred
+func (contract *Contract) resolveRepoLocation(stub shim.ChaincodeStubInterface, author string, repoName string) (string, string) {
+	for i := 0; i < maxRepoRedirects; i++ {
+		repoData, err := stub.GetState(getRepoKey(author, repoName))
+		if err != nil || len(repoData) == 0 {
+			break
+		}
+
+		structuredRepoData := map[string]string{}
+		err = json.Unmarshal(repoData, &structuredRepoData)
+		if err != nil || structuredRepoData["docName"] != "repoRedirect" {
+			break
+		}
+
+		fmt.Println("Repo has been transferred to: ", structuredRepoData["redirectAuthor"])
+		author = structuredRepoData["redirectAuthor"]
+		repoName = structuredRepoData["redirectName"]
+	}
+
+	return author, repoName
+}
+
+// checks if a repo is stored under the author and name, the redirects left behind by transfers and renames aside
+func (contract *Contract) repoStoredAt(stub shim.ChaincodeStubInterface, author string, repoName string) bool {
+	repoData, err := stub.GetState(getRepoKey(author, repoName))
+	if err != nil || len(repoData) == 0 {
+		return false
+	}
+
+	structuredRepoData := map[string]string{}
+	err = json.Unmarshal(repoData, &structuredRepoData)
+	return err != nil || structuredRepoData["docName"] != "repoRedirect"
+}
+
+func (contract *Contract) getRepoTransfer(stub shim.ChaincodeStubInterface, author string, repoName string) (RepoTransfer, error) {
+	var transfer RepoTransfer
+
+	transferKey, _ := getRepoTransferKey(stub, author, repoName)
+	transferData, err := stub.GetState(transferKey)
+	if err != nil || len(transferData) == 0 {
+		return transfer, errors.New("Repo " + repoName + " has no pending transfer")
+	}
+
+	err = json.Unmarshal(transferData, &transfer)
+	if err != nil {
+		return transfer, errors.New("Could not unmarshal repo transfer")
+	}
+
+	return transfer, nil
+}
+
+// returns the document of the repo stored under the key, read from the collection of private repos
+func (contract *Contract) getRepoDocument(stub shim.ChaincodeStubInterface, repoHash string) (map[string]string, error) {
+	structuredRepoData := map[string]string{}
+
+	repoData, err := stub.GetState(repoHash)
+	if err != nil || len(repoData) == 0 {
+		return structuredRepoData, errors.New("Could not find requested Repo")
+	}
+	err = json.Unmarshal(repoData, &structuredRepoData)
+	if err != nil {
+		return structuredRepoData, errors.New("Could not unmarashal requested repo")
+	}
+
+	if collection := structuredRepoData["privateCollection"]; collection != "" && structuredRepoData["docName"] == "repo" {
+		privateRepoData, err := stub.GetPrivateData(collection, repoHash)
+		if err != nil || len(privateRepoData) == 0 {
+			return structuredRepoData, errors.New("Could not read requested private repo")
+		}
+		structuredRepoData = map[string]string{}
+		err = json.Unmarshal(privateRepoData, &structuredRepoData)
+		if err != nil {
+			return structuredRepoData, errors.New("Could not unmarshal requested private repo")
+		}
+	}
+
+	return structuredRepoData, nil
 }
 
 func (contract *Contract) getRepoInstance(stub shim.ChaincodeStubInterface, args []string) (Repository, error) {
@@ -49,31 +303,104 @@ func (contract *Contract) getRepoInstance(stub shim.ChaincodeStubInterface, args
 	}
 
 	// getting the required information from first table.
-	repoHash := getRepoKey(args[0], args[1])
-	repoData, err := stub.GetState(repoHash)
-	if err != nil {
-		var repo Repository
-		fmt.Println("Could not find requested Repo: ", err)
-		return repo, errors.New("Could not find requested Repo")
-	}
-	fmt.Println("Found this repo:", string(repoData))
-	// unmarashaling the data
-	structuredRepoData := map[string]string{}
-	err = json.Unmarshal(repoData, &structuredRepoData)
+	repoAuthor, repoName := contract.resolveRepoLocation(stub, args[0], args[1])
+	repoHash := getRepoKey(repoAuthor, repoName)
+	structuredRepoData, err := contract.getRepoDocument(stub, repoHash)
 	if err != nil {
 		var repo Repository
-		fmt.Println("Could not unmarshal requested repo: ", err)
-		return repo, errors.New("Could not unmarashal requested repo")
+		fmt.Println("Could not read requested Repo: ", err)
+		return repo, err
 	}
 	users, _ := contract.getRepoUsers(stub, repoHash)
 
 	currentTime, _ := stub.GetTxTimestamp()
 
 	repo, _ := CreateNewRepo(structuredRepoData["name"], structuredRepoData["author"], structuredRepoData["directoryCID"], nil, users, currentTime.AsTime())
+	repo.RequireSignedCommits, _ = strconv.ParseBool(structuredRepoData["requireSignedCommits"])
+	repo.OwnershipRecoveryQuorum, _ = strconv.Atoi(structuredRepoData["ownershipRecoveryQuorum"])
+	repo.RequiredApprovals, _ = strconv.Atoi(structuredRepoData["requiredApprovals"])
+	json.Unmarshal([]byte(structuredRepoData["endorsingOrgs"]), &repo.EndorsingOrgs)
+	repo.PrivateCollection = structuredRepoData["privateCollection"]
+	repo.KeyEpoch, _ = strconv.Atoi(structuredRepoData["keyEpoch"])
+
+	// the content of private repos is read from their collection
+	getQueryResult := stub.GetQueryResult
+	if repo.IsPrivate() {
+		getQueryResult = func(query string) (shim.StateQueryIteratorInterface, error) {
+			return stub.GetPrivateDataQueryResult(repo.PrivateCollection, query)
+		}
+	}
+
+	// getting the organization owning the repo, if any
+	if organization, err := contract.getOrganization(stub, repo.Author); err == nil {
+		repo.Organization = &organization
+	}
+
+	// getting the teams that have been granted access
+	for principal := range repo.Access {
+		if organization, teamName, isTeam := ParseTeamPrincipal(principal); isTeam {
+			team, err := contract.getTeam(stub, organization, teamName)
+			if err == nil {
+				repo.AddTeam(team)
+			}
+		}
+	}
+
+	// getting the branch protection rules
+	protectionsIterator, err := stub.GetStateByPartialCompositeKey("index-BranchProtection", []string{repoHash})
+	if err != nil {
+		fmt.Println("Could not find branch protection rules: ", err)
+		var repo Repository
+		return repo, err
+	}
+	defer protectionsIterator.Close()
+	for protectionsIterator.HasNext() {
+		protectionString, err := protectionsIterator.Next()
+		if err != nil {
+			fmt.Println("Could not proceed to next branch protection rule: ", err)
+			var repo Repository
+			return repo, err
+		}
+
+		var rule BranchProtectionRule
+		err = json.Unmarshal(protectionString.Value, &rule)
+		if err != nil {
+			var repo Repository
+			fmt.Println("Could not unmarshal branch protection rule: ", err)
+			return repo, errors.New("Could not unmarshal branch protection rule")
+		}
+		repo.SetBranchProtection(rule)
+	}
+
+	// getting the tag protection rules
+	tagProtectionsIterator, err := stub.GetStateByPartialCompositeKey("index-TagProtection", []string{repoHash})
+	if err != nil {
+		fmt.Println("Could not find tag protection rules: ", err)
+		var repo Repository
+		return repo, err
+	}
+	defer tagProtectionsIterator.Close()
+	for tagProtectionsIterator.HasNext() {
+		protectionString, err := tagProtectionsIterator.Next()
+		if err != nil {
+			fmt.Println("Could not proceed to next tag protection rule: ", err)
+			var repo Repository
+			return repo, err
+		}
+
+		var rule TagProtectionRule
+		err = json.Unmarshal(protectionString.Value, &rule)
+		if err != nil {
+			var repo Repository
+			fmt.Println("Could not unmarshal tag protection rule: ", err)
+			return repo, errors.New("Could not unmarshal tag protection rule")
+		}
+		repo.SetTagProtection(rule)
+	}
 
 	// getting the repo branches
-	branchQueryString := fmt.Sprintf("{\"selector\": {\"docName\": \"branch\", \"repoID\": \"%s\"},\"fields\": [\"repoID\", \"branchName\"]}", repoHash)
-	branchResultsIterator, err := stub.GetQueryResult(branchQueryString)
+	branchQueryString := fmt.Sprintf("{\"selector\": {\"docName\": \"branch\", \"repoID\": \"%s\"},\"fields\": [\"repoID\", \"branchName\", \"head\"]}", repoHash)
+	branchResultsIterator, err := getQueryResult(branchQueryString)
 	if err != nil {
 		fmt.Println("Could not find Requested Branch: ", err)
 		var repo Repository
@@ -106,8 +433,8 @@ func (contract *Contract) getRepoInstance(stub shim.ChaincodeStubInterface, args
 		}
 
 		//adding branch commits
-		commitsQueryString := fmt.Sprintf("{\"selector\": {\"docName\": \"commit\", \"repoID\": \"%s\", \"branchName\": \"%s\"},\"fields\": [\"repoID\", \"branchName\", \"hash\", \"message\", \"author\", \"authorEmail\", \"timestamp\", \"parentHashes\", \"storageHashes\"]}", repoHash, branch.Name)
-		commitsResultsIterator, err := stub.GetQueryResult(commitsQueryString)
+		commitsQueryString := fmt.Sprintf("{\"selector\": {\"docName\": \"commit\", \"repoID\": \"%s\", \"branchName\": \"%s\"},\"fields\": [\"repoID\", \"branchName\", \"hash\", \"message\", \"author\", \"authorEmail\", \"authorID\", \"timestamp\", \"parentHashes\", \"storageHashes\", \"signature\", \"signatureFormat\", \"verified\", \"keyEpoch\"]}", repoHash, branch.Name)
+		commitsResultsIterator, err := getQueryResult(commitsQueryString)
 		if err != nil {
 			var repo Repository
 			fmt.Println("Could not find requested commit: ", err)
@@ -139,8 +466,17 @@ func (contract *Contract) getRepoInstance(stub shim.ChaincodeStubInterface, args
 			parsedTimestamp, _ := time.Parse(time.RFC3339Nano, structuredCommitData["timestamp"])
 
 			commit, _ := CreateNewCommit(structuredCommitData["message"], structuredCommitData["author"], structuredCommitData["authorEmail"], structuredCommitData["hash"], parsedTimestamp, ph, sh)
+			commit.AuthorID = structuredCommitData["authorID"]
+			// commits stored before personal data was kept private embed the author's email
+			if commit.AuthorEmail == "" {
+				commit.AuthorEmail = contract.getCommitEmail(stub, repo, commit.Hash)
+			}
+			commit.Signature = structuredCommitData["signature"]
+			commit.SignatureFormat = structuredCommitData["signatureFormat"]
+			commit.Verified, _ = strconv.ParseBool(structuredCommitData["verified"])
+			commit.KeyEpoch, _ = strconv.Atoi(structuredCommitData["keyEpoch"])
 			fmt.Println("and the commit became \t", commit)
-			commitAdded, err := repo.AddCommit(commit, branch.Name, true)
+			commitAdded, err := repo.AddCommit(commit, branch.Name, true, false)
 			if commitAdded {
 				fmt.Println("Commit added to branch: ", commit)
 			} else {
@@ -148,18 +484,100 @@ func (contract *Contract) getRepoInstance(stub shim.ChaincodeStubInterface, args
 			}
 		}
 
+		// branches stored before they kept their head point at their newest commit
+		loadedBranch := repo.Branches[branch.Name]
+		loadedBranch.Head = structuredBranchData["head"]
+		if loadedBranch.Head == "" {
+			newest, _ := loadedBranch.NewestCommit()
+			loadedBranch.Head = newest.Hash
+		}
+		repo.Branches[branch.Name] = loadedBranch
+
 	}
 
 	fmt.Println("This is the final fetched Repo: ", repo)
 	return repo, nil
 }
 
-func (contract *Contract) queryRepo(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	// repoAuthor, repoName
-	_, err := contract.getLoggedInUser(stub)
+// checks that the caller's organization can read the content of the repo, which only members of the
+// organization owning the private data collection of a private repo can
+func (contract *Contract) checkCollectionMembership(stub shim.ChaincodeStubInterface, repo Repository) error {
+	if !repo.IsPrivate() {
+		return nil
+	}
+
+	identity, err := getCallerIdentity(stub)
+	if err != nil {
+		return err
+	}
+
+	if GetOrgCollection(identity.MSPID) != repo.PrivateCollection {
+		return errors.New("Repo " + repo.Name + " is private to the members of " + repo.GetPrivateOrg())
+	}
+	return nil
+}
+
+// returns the payload of a write to the repo, which is passed under the key of the transient map for private
+// repos so that it is not recorded on the ledger, and as the argument at the index otherwise
+func getRepoPayload(stub shim.ChaincodeStubInterface, repo Repository, args []string, index int, transientKey string) (string, error) {
+	if !repo.IsPrivate() {
+		if len(args) <= index {
+			return "", errors.New("Incorrect number of arguments. Expecting " + strconv.Itoa(index+1) + ".")
+		}
+		return args[index], nil
+	}
+
+	if len(args) > index {
+		return "", errors.New("The content of private repo " + repo.Name + " must be passed through the transient map")
+	}
+
+	transientMap, err := stub.GetTransient()
+	if err != nil {
+		return "", errors.New("Could not read the transient map")
+	}
+
+	payload, exist := transientMap[transientKey]
+	if !exist {
+		return "", errors.New("Transient map does not contain " + transientKey)
+	}
+
+	return string(payload), nil
+}
+
+// returns an optional argument of a write to the repo that follows its payload, passed as the argument
+// at the index, or under its name in the transient map for private repos. It is empty when not passed.
+func getRepoOptionalArg(stub shim.ChaincodeStubInterface, repo Repository, args []string, index int, name string) (string, error) {
+	if !repo.IsPrivate() {
+		if len(args) <= index {
+			return "", nil
+		}
+		return args[index], nil
+	}
+
+	if len(args) > index {
+		return "", errors.New("The content of private repo " + repo.Name + " must be passed through the transient map")
+	}
+
+	transientMap, err := stub.GetTransient()
 	if err != nil {
+		return "", errors.New("Could not read the transient map")
+	}
+
+	return string(transientMap[name]), nil
+}
+
+// the error returned when a user, or an anonymous caller, cannot read a repo
+func readAccessDenied(userName string, repoName string) peer.Response {
+	if userName == "" {
 		return shim.Error("Please log in first!")
 	}
+	return shim.Error("User " + userName + " does not have read access to " + repoName)
+}
+
+func (contract *Contract) queryRepo(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName
+	// anonymous users can read repos that are visible to them
+	loggedInUser, _ := contract.getLoggedInUser(stub)
 
 	fmt.Println("Querying the ledger .. getRepo", args)
 
@@ -167,14 +585,21 @@ func (contract *Contract) queryRepo(stub shim.ChaincodeStubInterface, args []str
 		return shim.Error("Incorrect number of arguments. Expecting 2.")
 	}
 
-	repoHash := getRepoKey(args[0], args[1])
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
 
-	repoData, err := stub.GetState(repoHash)
+	if !repo.CanRead(loggedInUser.Name) {
+		return readAccessDenied(loggedInUser.Name, args[1])
+	}
 
+	structuredRepoData, err := contract.getRepoDocument(stub, getRepoKey(repo.Author, repo.Name))
 	if err != nil {
 		return shim.Error("Repo does not exist")
 	}
 
+	repoData, _ := json.Marshal(structuredRepoData)
 	fmt.Println("Found this repo:", string(repoData))
 
 	return shim.Success(repoData)
@@ -182,10 +607,8 @@ func (contract *Contract) queryRepo(stub shim.ChaincodeStubInterface, args []str
 
 func (contract *Contract) clone(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	// repoAuthor, repoName
-	loggedInUser, err := contract.getLoggedInUser(stub)
-	if err != nil {
-		return shim.Error("Please log in first!")
-	}
+	// anonymous users can read repos that are visible to them
+	loggedInUser, _ := contract.getLoggedInUser(stub)
 
 	fmt.Println("Querying the ledger .. clone", args)
 
@@ -199,7 +622,11 @@ func (contract *Contract) clone(stub shim.ChaincodeStubInterface, args []string)
 	}
 
 	if !repo.CanRead(loggedInUser.Name) {
-		return shim.Error("User " + loggedInUser.Name + " does not have read access to " + args[1])
+		return readAccessDenied(loggedInUser.Name, args[1])
+	}
+
+	if err := contract.checkCollectionMembership(stub, repo); err != nil {
+		return shim.Error(err.Error())
 	}
 
 	fmt.Println("Found this repo:", repo)
@@ -210,10 +637,8 @@ func (contract *Contract) clone(stub shim.ChaincodeStubInterface, args []string)
 
 func (contract *Contract) queryBranches(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	// repoAuthor, repoName
-	loggedInUser, err := contract.getLoggedInUser(stub)
-	if err != nil {
-		return shim.Error("Please log in first!")
-	}
+	// anonymous users can read repos that are visible to them
+	loggedInUser, _ := contract.getLoggedInUser(stub)
 
 	fmt.Println("Querying the ledger .. queryBranches", args)
 
@@ -226,7 +651,11 @@ func (contract *Contract) queryBranches(stub shim.ChaincodeStubInterface, args [
 		return shim.Error("Repo does not exist")
 	}
 	if !repo.CanRead(loggedInUser.Name) {
-		return shim.Error("User " + loggedInUser.Name + " does not have read access to " + args[1])
+		return readAccessDenied(loggedInUser.Name, args[1])
+	}
+
+	if err := contract.checkCollectionMembership(stub, repo); err != nil {
+		return shim.Error(err.Error())
 	}
 
 	fmt.Println("Found these branches:", repo.GetBranches())
@@ -237,10 +666,8 @@ func (contract *Contract) queryBranches(stub shim.ChaincodeStubInterface, args [
 
 func (contract *Contract) queryBranch(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	// repoAuthor, repoName, branchName
-	loggedInUser, err := contract.getLoggedInUser(stub)
-	if err != nil {
-		return shim.Error("Please log in first!")
-	}
+	// anonymous users can read repos that are visible to them
+	loggedInUser, _ := contract.getLoggedInUser(stub)
 
 	fmt.Println("Querying the ledger .. queryBranch", args)
 
@@ -253,7 +680,11 @@ func (contract *Contract) queryBranch(stub shim.ChaincodeStubInterface, args []s
 		return shim.Error("Repo does not exist")
 	}
 	if !repo.CanRead(loggedInUser.Name) {
-		return shim.Error("User " + loggedInUser.Name + " does not have read access to " + args[1])
+		return readAccessDenied(loggedInUser.Name, args[1])
+	}
+
+	if err := contract.checkCollectionMembership(stub, repo); err != nil {
+		return shim.Error(err.Error())
 	}
 
 	if !repo.BranchExists(args[2]) {
@@ -271,10 +702,8 @@ func (contract *Contract) queryBranch(stub shim.ChaincodeStubInterface, args []s
 func (contract *Contract) queryBranchCommitsAfter(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	// repoAuthor, repoName, branchName, commitId
 
-	loggedInUser, err := contract.getLoggedInUser(stub)
-	if err != nil {
-		return shim.Error("Please log in first!")
-	}
+	// anonymous users can read repos that are visible to them
+	loggedInUser, _ := contract.getLoggedInUser(stub)
 
 	fmt.Println("Querying the ledger .. queryBranchCommitsAfter", args)
 
@@ -288,7 +717,11 @@ func (contract *Contract) queryBranchCommitsAfter(stub shim.ChaincodeStubInterfa
 	}
 
 	if !repo.CanRead(loggedInUser.Name) {
-		return shim.Error("User " + loggedInUser.Name + " does not have read access to " + args[1])
+		return readAccessDenied(loggedInUser.Name, args[1])
+	}
+
+	if err := contract.checkCollectionMembership(stub, repo); err != nil {
+		return shim.Error(err.Error())
 	}
 
 	if !repo.BranchExists(args[2]) {
@@ -326,10 +759,8 @@ func (contract *Contract) queryBranchCommitsAfter(stub shim.ChaincodeStubInterfa
 func (contract *Contract) queryLastBranchCommit(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	// repoAuthor, repoName, branchName
 
-	loggedInUser, err := contract.getLoggedInUser(stub)
-	if err != nil {
-		return shim.Error("Please log in first!")
-	}
+	// anonymous users can read repos that are visible to them
+	loggedInUser, _ := contract.getLoggedInUser(stub)
 
 	fmt.Println("Querying the ledger .. queryBranchCommits", args)
 
@@ -343,7 +774,11 @@ func (contract *Contract) queryLastBranchCommit(stub shim.ChaincodeStubInterface
 	}
 
 	if !repo.CanRead(loggedInUser.Name) {
-		return shim.Error("User " + loggedInUser.Name + " does not have read access to " + args[1])
+		return readAccessDenied(loggedInUser.Name, args[1])
+	}
+
+	if err := contract.checkCollectionMembership(stub, repo); err != nil {
+		return shim.Error(err.Error())
 	}
 
 	if !repo.BranchExists(args[2]) {
@@ -354,23 +789,72 @@ func (contract *Contract) queryLastBranchCommit(stub shim.ChaincodeStubInterface
 	branch := repo.Branches[args[2]]
 	fmt.Println("Found this branch:", branch)
 
-	t := time.Unix(0, 0)
-	hash := ""
-	// get all commits after this time
-	for _, log := range branch.Commits {
-		if log.Timestamp.UnixMilli() >= t.UnixMilli() {
-			t = log.Timestamp
-			hash = log.Hash
-		}
+	return shim.Success([]byte(branch.Head))
+}
+
+// returns the email of the user from the collection of personal data,
+// empty when the user's data has been erased or the caller's organization cannot read it.
+// The email is personal data, so it is only read back for the user themselves
+func (contract *Contract) getUserEmail(stub shim.ChaincodeStubInterface, userName string) string {
+	piiKey, _ := getUserPIIKey(stub, userName)
+	piiData, err := stub.GetPrivateData(UserPIICollection, piiKey)
+	if err != nil || len(piiData) == 0 {
+		return contract.getLegacyUserEmail(stub, userName)
+	}
+
+	var pii UserPII
+	err = json.Unmarshal(piiData, &pii)
+	if err != nil {
+		return ""
 	}
 
-	return shim.Success([]byte(hash))
+	return pii.Email
 }
 
-func (contract *Contract) getUserPublicInfo(stub shim.ChaincodeStubInterface, userName string) (UserPublicInfo, peer.Response) {
-	var userInfo UserPublicInfo
+// returns the git author email of a commit of the repo from the collection of personal data,
+// empty when it has been erased or the caller's organization cannot read it
+func (contract *Contract) getCommitEmail(stub shim.ChaincodeStubInterface, repo Repository, commitHash string) string {
+	piiKey, _ := getCommitPIIKey(stub, repo.Author, repo.Name, commitHash)
+	piiData, err := stub.GetPrivateData(UserPIICollection, piiKey)
+	if err != nil || len(piiData) == 0 {
+		return ""
+	}
 
-	userQueryString := fmt.Sprintf("{\"selector\": {\"docName\": \"user\", \"name\": \"%s\"},\"fields\": [\"name\", \"email\", \"publicKey\"]}", userName)
+	structuredPIIData := map[string]string{}
+	err = json.Unmarshal(piiData, &structuredPIIData)
+	if err != nil {
+		return ""
+	}
+
+	return structuredPIIData["email"]
+}
+
+// returns the email stored in the user document by users registered before personal data was kept private
+func (contract *Contract) getLegacyUserEmail(stub shim.ChaincodeStubInterface, userName string) string {
+	userQueryString := fmt.Sprintf("{\"selector\": {\"docName\": \"user\", \"name\": \"%s\"},\"fields\": [\"email\"]}", userName)
+	userResultsIterator, err := stub.GetQueryResult(userQueryString)
+	if err != nil {
+		return ""
+	}
+	defer userResultsIterator.Close()
+
+	if !userResultsIterator.HasNext() {
+		return ""
+	}
+	userString, err := userResultsIterator.Next()
+	if err != nil {
+		return ""
+	}
+
+	structuredUserData := map[string]string{}
+	_ = json.Unmarshal(userString.Value, &structuredUserData)
+	return structuredUserData["email"]
+}
+
+func (contract *Contract) getUserPublicInfo(stub shim.ChaincodeStubInterface, userName string) (UserPublicInfo, peer.Response) {
+	var userInfo UserPublicInfo
+
+	userQueryString := fmt.Sprintf("{\"selector\": {\"docName\": \"user\", \"name\": \"%s\"},\"fields\": [\"name\", \"emailHash\", \"publicKey\"]}", userName)
 	userResultsIterator, err := stub.GetQueryResult(userQueryString)
 	if err != nil || !userResultsIterator.HasNext() {
 		fmt.Println("Could not find Requested User: ", err)
@@ -394,13 +878,76 @@ func (contract *Contract) getUserPublicInfo(stub shim.ChaincodeStubInterface, us
 		}
 
 		userInfo.Name = structuredUserData["name"]
-		userInfo.Email = structuredUserData["email"]
 		userInfo.PublicKey = structuredUserData["publicKey"]
+		userInfo.EmailHash = structuredUserData["emailHash"]
 	}
 
 	return userInfo, shim.Success([]byte(""))
 }
 
+// returns the ordered key history of a user, from the oldest to the newest key.
+// Users registered before key history existed get their registered key back as a key valid since ever.
+func (contract *Contract) getUserKeys(stub shim.ChaincodeStubInterface, userInfo UserPublicInfo) ([]UserKey, error) {
+	keys := make([]UserKey, 0)
+
+	keysIterator, err := stub.GetStateByPartialCompositeKey("index-UserKey", []string{userInfo.Name})
+	if err != nil {
+		fmt.Println("Could not find user keys: ", err)
+		return keys, errors.New("Could not find keys of user " + userInfo.Name)
+	}
+	defer keysIterator.Close()
+
+	for keysIterator.HasNext() {
+		keyString, err := keysIterator.Next()
+		if err != nil {
+			fmt.Println("Could not proceed to next user key: ", err)
+			return keys, errors.New("Could not proceed to next user key")
+		}
+
+		var key UserKey
+		err = json.Unmarshal(keyString.Value, &key)
+		if err != nil {
+			fmt.Println("Could not unmarshal user key: ", err)
+			return keys, errors.New("Could not unmarshal user key")
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 && userInfo.PublicKey != "" {
+		legacyKey, err := CreateNewUserKey(userInfo.Name, DefaultUserKeyLabel, userInfo.PublicKey, time.Unix(0, 0))
+		if err == nil {
+			keys = append(keys, legacyKey)
+		}
+	}
+
+	SortUserKeys(keys)
+
+	return keys, nil
+}
+
+func (contract *Contract) queryUserKeys(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// userName
+
+	fmt.Println("Querying the ledger .. queryUserKeys", args)
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1.")
+	}
+
+	userInfo, failMessage := contract.getUserPublicInfo(stub, args[0])
+	if failMessage.Message != "" {
+		return failMessage
+	}
+
+	keys, err := contract.getUserKeys(stub, userInfo)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	serialized, _ := json.Marshal(keys)
+	return shim.Success(serialized)
+}
+
 func (contract *Contract) queryUser(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	// userName
 
@@ -448,7 +995,7 @@ func (contract *Contract) getRepoUsers(stub shim.ChaincodeStubInterface, repoHas
 
 	accessLog := make([]AccessLog, 0)
 
-	accessQueryString := fmt.Sprintf("{\"selector\": {\"docName\": \"userAccess\", \"repoID\": \"%s\"},\"fields\": [\"authorized\", \"userAccess\", \"authorizer\", \"timestamp\"]}, \"sort\": [{\"timestamp\": \"asc\"}],", repoHash)
+	accessQueryString := fmt.Sprintf("{\"selector\": {\"docName\": \"userAccess\", \"repoID\": \"%s\"},\"fields\": [\"authorized\", \"userAccess\", \"authorizer\", \"timestamp\", \"expiresAt\", \"visibility\", \"requestID\", \"requestStatus\"]}, \"sort\": [{\"timestamp\": \"asc\"}],", repoHash)
 	accessResultsIterator, err := stub.GetQueryResult(accessQueryString)
 	if err != nil {
 		fmt.Println("Could not find Repo Access: ", err)
@@ -481,15 +1028,70 @@ func (contract *Contract) getRepoUsers(stub shim.ChaincodeStubInterface, repoHas
 			fmt.Println("Could not parse UserAccess: ", access, err)
 			return accessLog, shim.Error("Could not parse UserAccess")
 		} else {
-			accessLog = append(accessLog, AccessLog{structuredAccessData["authorizer"], structuredAccessData["authorized"], parsedTimestamp, UserAccess(access)})
+			// grants recorded before expiry existed do not expire
+			parsedExpiresAt, _ := time.Parse(time.RFC3339Nano, structuredAccessData["expiresAt"])
+			accessLog = append(accessLog, AccessLog{
+				Authorizer:    structuredAccessData["authorizer"],
+				Authorized:    structuredAccessData["authorized"],
+				Timestamp:     parsedTimestamp,
+				UserAccess:    UserAccess(access),
+				ExpiresAt:     parsedExpiresAt,
+				Visibility:    RepoVisibility(structuredAccessData["visibility"]),
+				RequestID:     structuredAccessData["requestID"],
+				RequestStatus: AccessRequestStatus(structuredAccessData["requestStatus"]),
+			})
 		}
 	}
 
 	return accessLog, shim.Success([]byte(""))
 }
 
+// returns the repos that have granted access to the mentioned principal, a user or a team, at some point
+func (contract *Contract) getPrincipalRepos(stub shim.ChaincodeStubInterface, principal string) ([]Repository, error) {
+	repos := make([]Repository, 0)
+
+	queryString, _ := json.Marshal(map[string]interface{}{"selector": map[string]interface{}{"docName": "userAccess", "authorized": principal}, "fields": []string{"repoID"}})
+	accessResultsIterator, err := stub.GetQueryResult(string(queryString))
+	if err != nil {
+		return repos, errors.New("Could not find the repos of " + principal)
+	}
+	defer accessResultsIterator.Close()
+
+	repoIDs := make(map[string]bool)
+	for accessResultsIterator.HasNext() {
+		accessString, err := accessResultsIterator.Next()
+		if err != nil {
+			return repos, errors.New("Could not proceed to next user access")
+		}
+
+		structuredAccessData := map[string]string{}
+		_ = json.Unmarshal(accessString.Value, &structuredAccessData)
+		repoID := structuredAccessData["repoID"]
+		if repoIDs[repoID] {
+			continue
+		}
+		repoIDs[repoID] = true
+
+		// private repos whose collection cannot be read here are left out
+		structuredRepoData, err := contract.getRepoDocument(stub, repoID)
+		if err != nil || structuredRepoData["docName"] != "repo" {
+			continue
+		}
+
+		repo, err := contract.getRepoInstance(stub, []string{structuredRepoData["author"], structuredRepoData["name"]})
+		if err != nil {
+			return repos, err
+		}
+		repos = append(repos, repo)
+	}
+
+	return repos, nil
+}
+
 func (contract *Contract) queryRepoUserAccess(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	// repoAuthor, repoName
+	// anonymous users can read repos that are visible to them
+	loggedInUser, _ := contract.getLoggedInUser(stub)
 
 	fmt.Println("Querying the ledger .. queryRepoUserAccess", args)
 
@@ -497,13 +1099,679 @@ func (contract *Contract) queryRepoUserAccess(stub shim.ChaincodeStubInterface,
 		return shim.Error("Incorrect number of arguments. Expecting 2.")
 	}
 
-	repoHash := getRepoKey(args[0], args[1])
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.CanRead(loggedInUser.Name) {
+		return readAccessDenied(loggedInUser.Name, args[1])
+	}
 
-	users, failMessage := contract.getRepoUsers(stub, repoHash)
+	users, failMessage := contract.getRepoUsers(stub, getRepoKey(repo.Author, repo.Name))
 	if failMessage.Message != "" {
 		return failMessage
 	}
 
-	serialized, _ := json.Marshal(users)
+	currentTime, _ := stub.GetTxTimestamp()
+
+	serialized, _ := json.Marshal(GetAccessLogValidities(users, currentTime.AsTime()))
+	return shim.Success(serialized)
+}
+
+func (contract *Contract) queryEffectiveRepoAccess(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName
+	// anonymous users can read repos that are visible to them
+	loggedInUser, _ := contract.getLoggedInUser(stub)
+
+	fmt.Println("Querying the ledger .. queryEffectiveRepoAccess", args)
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.CanRead(loggedInUser.Name) {
+		return readAccessDenied(loggedInUser.Name, args[1])
+	}
+
+	serialized, _ := json.Marshal(repo.GetEffectiveAccesses())
+	return shim.Success(serialized)
+}
+
+func (contract *Contract) getAccessRequest(stub shim.ChaincodeStubInterface, author string, repoName string, requestID string) (AccessRequest, error) {
+	var request AccessRequest
+
+	requestKey, _ := getAccessRequestKey(stub, author, repoName, requestID)
+	requestData, err := stub.GetState(requestKey)
+	if err != nil || len(requestData) == 0 {
+		return request, errors.New("Access request " + requestID + " does not exist")
+	}
+
+	err = json.Unmarshal(requestData, &request)
+	if err != nil {
+		return request, errors.New("Could not unmarshal access request " + requestID)
+	}
+
+	return request, nil
+}
+
+// returns the pending access requests and invitations matching a CouchDB selector
+func (contract *Contract) getPendingAccessRequests(stub shim.ChaincodeStubInterface, selector map[string]interface{}) ([]AccessRequest, error) {
+	requests := make([]AccessRequest, 0)
+
+	selector["docName"] = "accessRequest"
+	selector["status"] = AccessRequestPending
+	queryString, _ := json.Marshal(map[string]interface{}{"selector": selector})
+
+	requestsIterator, err := stub.GetQueryResult(string(queryString))
+	if err != nil {
+		fmt.Println("Could not find access requests: ", err)
+		return requests, errors.New("Could not find access requests")
+	}
+	defer requestsIterator.Close()
+
+	for requestsIterator.HasNext() {
+		requestString, err := requestsIterator.Next()
+		if err != nil {
+			fmt.Println("Could not proceed to next access request: ", err)
+			return requests, errors.New("Could not proceed to next access request")
+		}
+
+		var request AccessRequest
+		err = json.Unmarshal(requestString.Value, &request)
+		if err != nil {
+			fmt.Println("Could not unmarshal access request: ", err)
+			return requests, errors.New("Could not unmarshal access request")
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+func (contract *Contract) queryRepoAccessRequests(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	fmt.Println("Querying the ledger .. queryRepoAccessRequests", args)
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityManageMetadata) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to see the access requests of this repo")
+	}
+
+	requests, err := contract.getPendingAccessRequests(stub, map[string]interface{}{"repoID": getRepoKey(repo.Author, repo.Name)})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	serialized, _ := json.Marshal(requests)
+	return shim.Success(serialized)
+}
+
+func (contract *Contract) queryUserAccessRequests(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// no arguments, returns the pending requests filed by or invitations sent to the logged in user
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	fmt.Println("Querying the ledger .. queryUserAccessRequests", args)
+
+	requests, err := contract.getPendingAccessRequests(stub, map[string]interface{}{"user": loggedInUser.Name})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	serialized, _ := json.Marshal(requests)
+	return shim.Success(serialized)
+}
+
+func (contract *Contract) getOwnershipRecovery(stub shim.ChaincodeStubInterface, author string, repoName string, recoveryID string) (OwnershipRecovery, error) {
+	var recovery OwnershipRecovery
+
+	recoveryKey, _ := getOwnershipRecoveryKey(stub, author, repoName, recoveryID)
+	recoveryData, err := stub.GetState(recoveryKey)
+	if err != nil || len(recoveryData) == 0 {
+		return recovery, errors.New("Ownership recovery " + recoveryID + " does not exist")
+	}
+
+	err = json.Unmarshal(recoveryData, &recovery)
+	if err != nil {
+		return recovery, errors.New("Could not unmarshal ownership recovery " + recoveryID)
+	}
+
+	return recovery, nil
+}
+
+func (contract *Contract) queryOwnershipRecoveries(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	fmt.Println("Querying the ledger .. queryOwnershipRecoveries", args)
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityRecoverOwnership) && !repo.Can(loggedInUser.Name, CapabilityAdminister) {
+		return shim.Error("User is not authorized to edit this repo")
+	}
+
+	recoveries := make([]OwnershipRecovery, 0)
+
+	recoveriesIterator, err := stub.GetStateByPartialCompositeKey("index-OwnershipRecovery", []string{getRepoKey(repo.Author, repo.Name)})
+	if err != nil {
+		return shim.Error("Could not find ownership recoveries")
+	}
+	defer recoveriesIterator.Close()
+
+	for recoveriesIterator.HasNext() {
+		recoveryString, err := recoveriesIterator.Next()
+		if err != nil {
+			return shim.Error("Could not proceed to next ownership recovery")
+		}
+
+		var recovery OwnershipRecovery
+		err = json.Unmarshal(recoveryString.Value, &recovery)
+		if err != nil {
+			return shim.Error("Could not unmarshal ownership recovery")
+		}
+		recoveries = append(recoveries, recovery)
+	}
+
+	serialized, _ := json.Marshal(recoveries)
+	return shim.Success(serialized)
+}
+
+// returns the key envelopes of the repo for the epoch
+func (contract *Contract) getRepoKeyEnvelopes(stub shim.ChaincodeStubInterface, repo Repository, epoch int) ([]KeyEnvelope, error) {
+	envelopes := make([]KeyEnvelope, 0)
+
+	envelopesIterator, err := stub.GetStateByPartialCompositeKey("index-RepoKeyEnvelope", []string{getRepoKey(repo.Author, repo.Name), strconv.Itoa(epoch)})
+	if err != nil {
+		return envelopes, errors.New("Could not find key envelopes")
+	}
+	defer envelopesIterator.Close()
+
+	for envelopesIterator.HasNext() {
+		envelopeString, err := envelopesIterator.Next()
+		if err != nil {
+			return envelopes, errors.New("Could not proceed to next key envelope")
+		}
+
+		var envelope KeyEnvelope
+		err = json.Unmarshal(envelopeString.Value, &envelope)
+		if err != nil {
+			return envelopes, errors.New("Could not unmarshal key envelope")
+		}
+		envelopes = append(envelopes, envelope)
+	}
+
+	return envelopes, nil
+}
+
+func (contract *Contract) queryRepoKeyEnvelope(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, optional:epoch (the current epoch by default)
+	// returns the content key of the repo wrapped for the logged in user
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	fmt.Println("Querying the ledger .. queryRepoKeyEnvelope", args)
+
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 or 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.CanRead(loggedInUser.Name) {
+		return readAccessDenied(loggedInUser.Name, args[1])
+	}
+
+	if !repo.IsEncrypted() {
+		return shim.Error("Content of repo " + repo.Name + " is not encrypted")
+	}
+
+	epoch := repo.KeyEpoch
+	if len(args) == 3 {
+		epoch, err = strconv.Atoi(args[2])
+		if err != nil || epoch < 1 || epoch > repo.KeyEpoch {
+			return shim.Error("could not parse key epoch")
+		}
+	}
+
+	envelopeKey, _ := getRepoKeyEnvelopeKey(stub, repo.Author, repo.Name, epoch, loggedInUser.Name)
+	envelopeData, err := stub.GetState(envelopeKey)
+	if err != nil || len(envelopeData) == 0 {
+		return shim.Error("No key envelope of epoch " + strconv.Itoa(epoch) + " has been wrapped for user " + loggedInUser.Name)
+	}
+
+	return shim.Success(envelopeData)
+}
+
+// returns the reflog of a branch from the newest to the oldest entry
+func (contract *Contract) getReflog(stub shim.ChaincodeStubInterface, repo Repository, branchName string) ([]ReflogEntry, error) {
+	entries := make([]ReflogEntry, 0)
+
+	keys := []string{getRepoKey(repo.Author, repo.Name), branchName}
+	var entriesIterator shim.StateQueryIteratorInterface
+	var err error
+	if repo.IsPrivate() {
+		entriesIterator, err = stub.GetPrivateDataByPartialCompositeKey(repo.PrivateCollection, "index-Reflog", keys)
+	} else {
+		entriesIterator, err = stub.GetStateByPartialCompositeKey("index-Reflog", keys)
+	}
+	if err != nil {
+		return entries, errors.New("Could not find the reflog")
+	}
+	defer entriesIterator.Close()
+
+	for entriesIterator.HasNext() {
+		entryString, err := entriesIterator.Next()
+		if err != nil {
+			return entries, errors.New("Could not proceed to next reflog entry")
+		}
+
+		var entry ReflogEntry
+		err = json.Unmarshal(entryString.Value, &entry)
+		if err != nil {
+			return entries, errors.New("Could not unmarshal reflog entry")
+		}
+		entries = append(entries, entry)
+	}
+
+	SortReflog(entries)
+	return entries, nil
+}
+
+func (contract *Contract) queryReflog(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, branchName
+	// the branch may have been deleted or renamed since
+
+	// anonymous users can read repos that are visible to them
+	loggedInUser, _ := contract.getLoggedInUser(stub)
+
+	fmt.Println("Querying the ledger .. queryReflog", args)
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.CanRead(loggedInUser.Name) {
+		return readAccessDenied(loggedInUser.Name, args[1])
+	}
+
+	if err := contract.checkCollectionMembership(stub, repo); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	entries, err := contract.getReflog(stub, repo, args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	serialized, _ := json.Marshal(entries)
+	return shim.Success(serialized)
+}
+
+func (contract *Contract) getPullRequest(stub shim.ChaincodeStubInterface, repo Repository, pullRequestID string) (PullRequest, error) {
+	var pullRequest PullRequest
+
+	pullRequestKey, _ := getPullRequestKey(stub, repo.Author, repo.Name, pullRequestID)
+	var pullRequestData []byte
+	var err error
+	if repo.IsPrivate() {
+		pullRequestData, err = stub.GetPrivateData(repo.PrivateCollection, pullRequestKey)
+	} else {
+		pullRequestData, err = stub.GetState(pullRequestKey)
+	}
+	if err != nil || len(pullRequestData) == 0 {
+		return pullRequest, errors.New("Pull request " + pullRequestID + " does not exist")
+	}
+
+	err = json.Unmarshal(pullRequestData, &pullRequest)
+	if err != nil {
+		return pullRequest, errors.New("Could not unmarshal pull request " + pullRequestID)
+	}
+
+	return pullRequest, nil
+}
+
+// returns the pull requests matching the selector, from the world state or from a private data collection
+func (contract *Contract) getPullRequests(stub shim.ChaincodeStubInterface, collection string, selector map[string]interface{}) ([]PullRequest, error) {
+	pullRequests := make([]PullRequest, 0)
+
+	selector["docName"] = "pullRequest"
+	queryString, _ := json.Marshal(map[string]interface{}{"selector": selector})
+
+	var pullRequestsIterator shim.StateQueryIteratorInterface
+	var err error
+	if collection != "" {
+		pullRequestsIterator, err = stub.GetPrivateDataQueryResult(collection, string(queryString))
+	} else {
+		pullRequestsIterator, err = stub.GetQueryResult(string(queryString))
+	}
+	if err != nil {
+		fmt.Println("Could not find pull requests: ", err)
+		return pullRequests, errors.New("Could not find pull requests")
+	}
+	defer pullRequestsIterator.Close()
+
+	for pullRequestsIterator.HasNext() {
+		pullRequestString, err := pullRequestsIterator.Next()
+		if err != nil {
+			fmt.Println("Could not proceed to next pull request: ", err)
+			return pullRequests, errors.New("Could not proceed to next pull request")
+		}
+
+		var pullRequest PullRequest
+		err = json.Unmarshal(pullRequestString.Value, &pullRequest)
+		if err != nil {
+			fmt.Println("Could not unmarshal pull request: ", err)
+			return pullRequests, errors.New("Could not unmarshal pull request")
+		}
+		pullRequests = append(pullRequests, pullRequest)
+	}
+
+	return pullRequests, nil
+}
+
+func (contract *Contract) queryRepoPullRequests(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, optional:state ("open", "merged" or "closed")
+
+	// anonymous users can read repos that are visible to them
+	loggedInUser, _ := contract.getLoggedInUser(stub)
+
+	fmt.Println("Querying the ledger .. queryRepoPullRequests", args)
+
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 or 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.CanRead(loggedInUser.Name) {
+		return readAccessDenied(loggedInUser.Name, args[1])
+	}
+
+	if err := contract.checkCollectionMembership(stub, repo); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	selector := map[string]interface{}{"repoID": getRepoKey(repo.Author, repo.Name)}
+	if len(args) == 3 {
+		selector["state"] = args[2]
+	}
+
+	pullRequests, err := contract.getPullRequests(stub, repo.PrivateCollection, selector)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	serialized, _ := json.Marshal(pullRequests)
+	return shim.Success(serialized)
+}
+
+func (contract *Contract) queryUserPullRequests(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// userName, optional:state ("open", "merged" or "closed")
+	// returns the pull requests opened by the user on repos the caller can read
+
+	// anonymous users can read repos that are visible to them
+	loggedInUser, _ := contract.getLoggedInUser(stub)
+
+	fmt.Println("Querying the ledger .. queryUserPullRequests", args)
+
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 or 2.")
+	}
+
+	selector := map[string]interface{}{"author": args[0]}
+	if len(args) == 2 {
+		selector["state"] = args[1]
+	}
+
+	pullRequests, err := contract.getPullRequests(stub, "", selector)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// pull requests of private repos are only found in the collection of the caller's organization
+	if identity, err := getCallerIdentity(stub); err == nil {
+		privatePullRequests, err := contract.getPullRequests(stub, GetOrgCollection(identity.MSPID), selector)
+		if err == nil {
+			pullRequests = append(pullRequests, privatePullRequests...)
+		}
+	}
+
+	readable := make(map[string]bool)
+	visiblePullRequests := make([]PullRequest, 0)
+	for _, pullRequest := range pullRequests {
+		repoID := getRepoKey(pullRequest.RepoAuthor, pullRequest.RepoName)
+		if _, checked := readable[repoID]; !checked {
+			repo, err := contract.getRepoInstance(stub, []string{pullRequest.RepoAuthor, pullRequest.RepoName})
+			readable[repoID] = err == nil && repo.CanRead(loggedInUser.Name)
+		}
+		if readable[repoID] {
+			visiblePullRequests = append(visiblePullRequests, pullRequest)
+		}
+	}
+
+	serialized, _ := json.Marshal(visiblePullRequests)
+	return shim.Success(serialized)
+}
+
+// returns the reviews of a pull request
+func (contract *Contract) getReviews(stub shim.ChaincodeStubInterface, repo Repository, pullRequestID string) ([]Review, error) {
+	reviews := make([]Review, 0)
+
+	keys := []string{getRepoKey(repo.Author, repo.Name), pullRequestID}
+	var reviewsIterator shim.StateQueryIteratorInterface
+	var err error
+	if repo.IsPrivate() {
+		reviewsIterator, err = stub.GetPrivateDataByPartialCompositeKey(repo.PrivateCollection, "index-Review", keys)
+	} else {
+		reviewsIterator, err = stub.GetStateByPartialCompositeKey("index-Review", keys)
+	}
+	if err != nil {
+		return reviews, errors.New("Could not find reviews")
+	}
+	defer reviewsIterator.Close()
+
+	for reviewsIterator.HasNext() {
+		reviewString, err := reviewsIterator.Next()
+		if err != nil {
+			return reviews, errors.New("Could not proceed to next review")
+		}
+
+		var review Review
+		err = json.Unmarshal(reviewString.Value, &review)
+		if err != nil {
+			return reviews, errors.New("Could not unmarshal review")
+		}
+		reviews = append(reviews, review)
+	}
+
+	return reviews, nil
+}
+
+func (contract *Contract) queryPullRequestReviews(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, pullRequestID
+	// reviews of another commit than the current head of the source branch are marked stale
+
+	// anonymous users can read repos that are visible to them
+	loggedInUser, _ := contract.getLoggedInUser(stub)
+
+	fmt.Println("Querying the ledger .. queryPullRequestReviews", args)
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.CanRead(loggedInUser.Name) {
+		return readAccessDenied(loggedInUser.Name, args[1])
+	}
+
+	if err := contract.checkCollectionMembership(stub, repo); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	pullRequest, err := contract.getPullRequest(stub, repo, args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	reviews, err := contract.getReviews(stub, repo, pullRequest.ID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	head := pullRequest.Head()
+	if _, sourceBranch, err := contract.getPullRequestSource(stub, repo, loggedInUser.Name, pullRequest.SourceRepoAuthor, pullRequest.SourceRepoName, pullRequest.SourceBranch); err == nil {
+		head = sourceBranch.Head
+	}
+	for i := range reviews {
+		reviews[i].Stale = reviews[i].Commit != head
+	}
+
+	serialized, _ := json.Marshal(reviews)
+	return shim.Success(serialized)
+}
+
+func (contract *Contract) getTag(stub shim.ChaincodeStubInterface, repo Repository, tagName string) (Tag, error) {
+	var tag Tag
+
+	tagKey, _ := getTagKey(stub, repo.Author, repo.Name, tagName)
+	var tagData []byte
+	var err error
+	if repo.IsPrivate() {
+		tagData, err = stub.GetPrivateData(repo.PrivateCollection, tagKey)
+	} else {
+		tagData, err = stub.GetState(tagKey)
+	}
+	if err != nil || len(tagData) == 0 {
+		return tag, errors.New("Tag " + tagName + " does not exist")
+	}
+
+	err = json.Unmarshal(tagData, &tag)
+	if err != nil {
+		return tag, errors.New("Could not unmarshal tag " + tagName)
+	}
+
+	return tag, nil
+}
+
+// returns the tags of a repo sorted by name
+func (contract *Contract) getTags(stub shim.ChaincodeStubInterface, repo Repository) ([]Tag, error) {
+	tags := make([]Tag, 0)
+
+	keys := []string{getRepoKey(repo.Author, repo.Name)}
+	var tagsIterator shim.StateQueryIteratorInterface
+	var err error
+	if repo.IsPrivate() {
+		tagsIterator, err = stub.GetPrivateDataByPartialCompositeKey(repo.PrivateCollection, "index-Tag", keys)
+	} else {
+		tagsIterator, err = stub.GetStateByPartialCompositeKey("index-Tag", keys)
+	}
+	if err != nil {
+		return tags, errors.New("Could not find tags")
+	}
+	defer tagsIterator.Close()
+
+	for tagsIterator.HasNext() {
+		tagString, err := tagsIterator.Next()
+		if err != nil {
+			return tags, errors.New("Could not proceed to next tag")
+		}
+
+		var tag Tag
+		err = json.Unmarshal(tagString.Value, &tag)
+		if err != nil {
+			return tags, errors.New("Could not unmarshal tag")
+		}
+		tags = append(tags, tag)
+	}
+
+	SortTags(tags)
+	return tags, nil
+}
+
+func (contract *Contract) queryTags(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName
+
+	// anonymous users can read repos that are visible to them
+	loggedInUser, _ := contract.getLoggedInUser(stub)
+
+	fmt.Println("Querying the ledger .. queryTags", args)
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.CanRead(loggedInUser.Name) {
+		return readAccessDenied(loggedInUser.Name, args[1])
+	}
+
+	if err := contract.checkCollectionMembership(stub, repo); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	tags, err := contract.getTags(stub, repo)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	serialized, _ := json.Marshal(tags)
 	return shim.Success(serialized)
 }
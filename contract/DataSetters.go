@@ -2,8 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-protos-go/peer"
@@ -13,6 +17,11 @@ func applyPair(stub shim.ChaincodeStubInterface, pair LedgerPair) bool {
 	fmt.Println("Key:\t" + string(pair.key))
 	fmt.Println("Value:\t" + string(pair.value))
 
+	if pair.collection != "" {
+		stub.PutPrivateData(pair.collection, pair.key, pair.value)
+		return true
+	}
+
 	stub.PutState(pair.key, pair.value)
 	return true
 }
@@ -20,10 +29,227 @@ func applyPair(stub shim.ChaincodeStubInterface, pair LedgerPair) bool {
 func deletePair(stub shim.ChaincodeStubInterface, pair LedgerPair) bool {
 	fmt.Println("Key:\t" + string(pair.key))
 
+	if pair.collection != "" {
+		stub.DelPrivateData(pair.collection, pair.key)
+		return true
+	}
+
 	stub.DelState(pair.key)
 	return true
 }
 
+// sets the key-level endorsement policy of the pairs' keys to the one of the mentioned branch,
+// or of the repo keys when branchName is empty
+func applyEndorsementPolicy(stub shim.ChaincodeStubInterface, repo Repository, branchName string, pairs []LedgerPair) error {
+	orgs := repo.GetEndorsingOrgs(branchName)
+	if len(orgs) == 0 {
+		// the keys keep the chaincode endorsement policy
+		return nil
+	}
+
+	policy, err := CreateEndorsementPolicy(orgs)
+	if err != nil {
+		return err
+	}
+
+	for _, pair := range pairs {
+		if pair.collection != "" {
+			err = stub.SetPrivateDataValidationParameter(pair.collection, pair.key, policy)
+		} else {
+			err = stub.SetStateValidationParameter(pair.key, policy)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sets the key-level endorsement policies of the repo, branch and commit keys of the repo
+func applyRepoEndorsementPolicies(stub shim.ChaincodeStubInterface, repo Repository) error {
+	repoPairs, _ := generateRepoDBPair(stub, repo)
+	err := applyEndorsementPolicy(stub, repo, "", repoPairs)
+	if err != nil {
+		return err
+	}
+
+	for _, branch := range repo.Branches {
+		branchPair, _ := generateRepoBranchDBPair(stub, repo, branch)
+		commitsPairs, _ := generateRepoBranchesCommitsDBPairUsingBranch(stub, repo, branch)
+		err = applyEndorsementPolicy(stub, repo, branch.Name, append(commitsPairs, branchPair))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// creates the reflog entry of a movement of the head of a branch by the transaction
+func newReflogEntry(stub shim.ChaincodeStubInterface, repo Repository, branchName string, action ReflogAction, oldHead string, newHead string, user string) ReflogEntry {
+	currentTime, _ := stub.GetTxTimestamp()
+
+	entry, _ := CreateNewReflogEntry(stub.GetTxID(), repo.Author, repo.Name, branchName, action, oldHead, newHead, user, currentTime.AsTime())
+	return entry
+}
+
+// stores an entry in the reflog of its branch and returns the stored pair
+func storeReflogEntry(stub shim.ChaincodeStubInterface, repo Repository, entry ReflogEntry) LedgerPair {
+	pair, _ := generateReflogEntryDBPair(stub, repo, entry)
+	applyPair(stub, pair)
+	return pair
+}
+
+// stores the git author emails of the commits in the collection of personal data.
+// Commits keep their email across the branches of the repo, so it is only removed with the repo or by its owner
+func storeCommitsPII(stub shim.ChaincodeStubInterface, repo Repository, commits []Commit) {
+	for _, commit := range commits {
+		if commit.AuthorEmail == "" {
+			continue
+		}
+		piiPair, _ := generateCommitPIIDBPair(stub, repo, commit)
+		applyPair(stub, piiPair)
+	}
+}
+
+// stores a branch of the repo whose history was replaced, deleting the commits it no longer holds
+// and storing the ones it gained since it was oldBranch. returns the stored pairs
+func storeBranchHistory(stub shim.ChaincodeStubInterface, repo Repository, oldBranch Branch, dropped []Commit) []LedgerPair {
+	branch := repo.Branches[oldBranch.Name]
+
+	for _, commit := range dropped {
+		commitPair, _ := generateRepoBranchCommitDBPair(stub, repo, branch.Name, commit)
+		deletePair(stub, commitPair)
+	}
+
+	pairs := make([]LedgerPair, 0)
+	for hash, commit := range branch.Commits {
+		if !oldBranch.CommitExists(hash) {
+			commitPair, _ := generateRepoBranchCommitDBPair(stub, repo, branch.Name, commit)
+			applyPair(stub, commitPair)
+			pairs = append(pairs, commitPair)
+			storeCommitsPII(stub, repo, []Commit{commit})
+		}
+	}
+
+	branchPair, _ := generateRepoBranchDBPair(stub, repo, branch)
+	applyPair(stub, branchPair)
+
+	return append(pairs, branchPair)
+}
+
+// The indexes of the records kept per repo besides its documents, whose composite keys start with the repo key.
+// Records of repoContentRecordIndexes are kept in the private data collection of private repos,
+// and records of repoPIIRecordIndexes in the collection of personal data.
+var repoRecordIndexes = []string{"index-Reflog", "index-PullRequest", "index-Review", "index-Tag",
+	"index-AccessRequest", "index-OwnershipRecovery", "index-RepoKeyEnvelope", "index-RepoTransfer", "index-CommitPII"}
+var repoContentRecordIndexes = []string{"index-Reflog", "index-PullRequest", "index-Review", "index-Tag"}
+var repoPIIRecordIndexes = []string{"index-CommitPII"}
+
+// returns the stored pairs of every record kept for the repo
+func getRepoRecordPairs(stub shim.ChaincodeStubInterface, repo Repository) ([]LedgerPair, error) {
+	pairs := make([]LedgerPair, 0)
+
+	keys := []string{getRepoKey(repo.Author, repo.Name)}
+	for _, indexName := range repoRecordIndexes {
+		collection := ""
+		if repo.IsPrivate() && slices.Contains(repoContentRecordIndexes, indexName) {
+			collection = repo.PrivateCollection
+		}
+		if slices.Contains(repoPIIRecordIndexes, indexName) {
+			collection = UserPIICollection
+		}
+
+		var recordsIterator shim.StateQueryIteratorInterface
+		var err error
+		if collection != "" {
+			recordsIterator, err = stub.GetPrivateDataByPartialCompositeKey(collection, indexName, keys)
+		} else {
+			recordsIterator, err = stub.GetStateByPartialCompositeKey(indexName, keys)
+		}
+		if err != nil {
+			return pairs, errors.New("Could not find the records of " + indexName)
+		}
+
+		for recordsIterator.HasNext() {
+			record, err := recordsIterator.Next()
+			if err != nil {
+				recordsIterator.Close()
+				return pairs, errors.New("Could not proceed to next record of " + indexName)
+			}
+			pairs = append(pairs, LedgerPair{key: record.Key, value: record.Value, collection: collection})
+		}
+		recordsIterator.Close()
+	}
+
+	return pairs, nil
+}
+
+// returns the record pairs of a repo moved to the key space of the repo now named newAuthor/newName
+func moveRepoRecordPairs(stub shim.ChaincodeStubInterface, pairs []LedgerPair, oldAuthor string, oldName string, newAuthor string, newName string) ([]LedgerPair, error) {
+	moved := make([]LedgerPair, 0, len(pairs))
+
+	newRepoHash := getRepoKey(newAuthor, newName)
+	for _, pair := range pairs {
+		indexName, attributes, err := stub.SplitCompositeKey(pair.key)
+		if err != nil || len(attributes) == 0 {
+			return moved, errors.New("Could not split the key of a repo record")
+		}
+		attributes[0] = newRepoHash
+
+		var value map[string]interface{}
+		err = json.Unmarshal(pair.value, &value)
+		if err != nil {
+			return moved, errors.New("Could not unmarshal a record of " + indexName)
+		}
+		value["repoID"] = newRepoHash
+		value["repoAuthor"] = newAuthor
+		value["repoName"] = newName
+		// pull requests between branches of the repo keep it as their source
+		if value["sourceRepoAuthor"] == oldAuthor && value["sourceRepoName"] == oldName {
+			value["sourceRepoAuthor"] = newAuthor
+			value["sourceRepoName"] = newName
+		}
+
+		var movedPair LedgerPair
+		movedPair.key, _ = stub.CreateCompositeKey(indexName, attributes)
+		movedPair.value, _ = json.Marshal(value)
+		movedPair.collection = pair.collection
+		moved = append(moved, movedPair)
+	}
+
+	return moved, nil
+}
+
+// moves the documents and records of a repo stored under oldAuthor/oldName to the key space of the repo,
+// whose author or name have changed, and leaves a redirect behind so that it can still be found under its old name
+func moveRepo(stub shim.ChaincodeStubInterface, oldDocuments []LedgerPair, oldRecords []LedgerPair, oldAuthor string, oldName string, repo Repository) error {
+	deletePairs(stub, oldRecords)
+	deletePairs(stub, oldDocuments)
+
+	documentPairs, _ := generateRepoDocumentsDBPair(stub, repo)
+	applyPairs(stub, documentPairs)
+
+	recordPairs, err := moveRepoRecordPairs(stub, oldRecords, oldAuthor, oldName, repo.Author, repo.Name)
+	if err != nil {
+		return err
+	}
+	applyPairs(stub, recordPairs)
+
+	err = applyRepoEndorsementPolicies(stub, repo)
+	if err != nil {
+		return errors.New("Could not set the endorsement policy of the repo! " + err.Error())
+	}
+	err = applyEndorsementPolicy(stub, repo, "", recordPairs)
+	if err != nil {
+		return errors.New("Could not set the endorsement policy of the repo! " + err.Error())
+	}
+
+	redirectPair, _ := generateRepoRedirectDBPair(stub, oldAuthor, oldName, repo.Author, repo.Name)
+	applyPair(stub, redirectPair)
+
+	return nil
+}
+
 func applyPairs(stub shim.ChaincodeStubInterface, pairs []LedgerPair) bool {
 	for ind, pair := range pairs {
 		fmt.Println("Adding index:\t", ind)
@@ -41,462 +267,3038 @@ func deletePairs(stub shim.ChaincodeStubInterface, pairs []LedgerPair) bool {
 }
 
 func (contract *Contract) registerNewUser(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	// userName, userEmail, publicKey
+	// userName, publicKey
+	// the user's email and a random salt are passed under "email" and "salt" in the transient map
+	// so that the email is never recorded on the ledger
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2.")
+	}
+
+	if strings.HasPrefix(args[0], TeamPrincipalPrefix) || args[0] == VisibilityLogPrincipal || args[0] == "" {
+		return shim.Error("Invalid user name " + args[0] + "!")
+	}
+
 	// Check if user already exists
 	_, failMessage := contract.getUserPublicInfo(stub, args[0])
 	if failMessage.Message == "" {
 		return shim.Error("User " + args[0] + " already exists!")
 	}
+	if _, err := contract.getOrganization(stub, args[0]); err == nil {
+		return shim.Error("An organization named " + args[0] + " already exists!")
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	userKey, err := CreateNewUserKey(args[0], DefaultUserKeyLabel, args[1], currentTime.AsTime())
+	if err != nil {
+		return shim.Error("Invalid public key: " + err.Error())
+	}
+
+	transientMap, err := stub.GetTransient()
+	if err != nil || len(transientMap["email"]) == 0 || len(transientMap["salt"]) == 0 {
+		return shim.Error("Transient map must contain the email and salt of the user")
+	}
+
+	pii, _ := CreateNewUserPII(args[0], string(transientMap["email"]), string(transientMap["salt"]))
+
+	user := User{PublicInfo: UserPublicInfo{Name: args[0], PublicKey: args[1], EmailHash: pii.EmailHash()}}
+
+	userPair, _ := generateUserDBPair(stub, user)
+	applyPair(stub, userPair)
+
+	piiPair, _ := generateUserPIIDBPair(stub, pii)
+	applyPair(stub, piiPair)
+
+	userKeyPair, _ := generateUserKeyDBPair(stub, userKey)
+	applyPair(stub, userKeyPair)
+
+	return shim.Success([]byte("User has successfully been created!"))
+}
+
+func (contract *Contract) requestLoginChallenge(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// userName
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1.")
+	}
+
+	identity, err := getCallerIdentity(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	userPublicInfo, failMessage := contract.getUserPublicInfo(stub, args[0])
+	if failMessage.Message != "" {
+		return shim.Error("User " + args[0] + " does not exist!")
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	challenge, _ := CreateNewLoginChallenge(stub.GetTxID(), userPublicInfo.Name, identity, currentTime.AsTime())
+
+	challengePair, _ := generateLoginChallengeDBPair(stub, challenge)
+	applyPair(stub, challengePair)
+
+	serialized, _ := json.Marshal(map[string]string{"challengeID": challenge.ChallengeID, "nonce": challenge.Nonce})
+	return shim.Success(serialized)
+}
+
+func (contract *Contract) logIn(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// userName, challengeID, signature
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	identity, err := getCallerIdentity(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	_, err = contract.getIdentityUserName(stub, identity)
+	if err == nil {
+		return shim.Error("Another user is currently logged in with this identity. Please log out before trying to log in!")
+	}
+
+	// a binding left by a key that is no longer valid is replaced
+	if userName, keyFingerprint, err := contract.getIdentityBinding(stub, identity); err == nil {
+		sessionPair, _ := generateUserSessionDBPair(stub, userName, keyFingerprint, identity)
+		deletePair(stub, sessionPair)
+	}
+
+	// Check if user already exists
+	userPublicInfo, failMessage := contract.getUserPublicInfo(stub, args[0])
+	if failMessage.Message != "" {
+		return shim.Error("User " + args[0] + " does not exist!")
+	}
+
+	challenge, err := contract.getLoginChallenge(stub, userPublicInfo.Name, args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+	if !challenge.IsValid(identity, currentTime.AsTime()) {
+		return shim.Error("Login challenge " + args[1] + " is expired, already used or was issued to another identity!")
+	}
+
+	userKeys, err := contract.getUserKeys(stub, userPublicInfo)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	validKeys, err := UserKeysValidAt(userKeys, currentTime.AsTime())
+	if err != nil {
+		return shim.Error("User " + args[0] + " has no valid key!")
+	}
 
-	user := User{PublicInfo: UserPublicInfo{args[0], args[1], args[2]}}
+	loginKey, err := VerifySignatureWithAnyKey(validKeys, []byte(challenge.Nonce), args[2], SignatureFormatAuto)
+	if err != nil {
+		return shim.Error("Could not verify login challenge for user " + args[0] + ": " + err.Error())
+	}
+
+	// Record the challenge as used so that it cannot be replayed
+	challenge.Used = true
+	challengePair, _ := generateLoginChallengeDBPair(stub, challenge)
+	applyPair(stub, challengePair)
+
+	// Bind the client identity of this transaction to the user for as long as the key it logged in with is valid
+	identityPair, _ := generateIdentityDBPair(stub, identity, userPublicInfo.Name, loginKey.Fingerprint)
+	applyPair(stub, identityPair)
+
+	sessionPair, _ := generateUserSessionDBPair(stub, userPublicInfo.Name, loginKey.Fingerprint, identity)
+	applyPair(stub, sessionPair)
+
+	return shim.Success([]byte("User " + userPublicInfo.Name + " has successfully logged in"))
+}
+
+func (contract *Contract) logOut(stub shim.ChaincodeStubInterface) peer.Response {
+
+	identity, err := getCallerIdentity(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Remove the binding between the client identity and the user
+	userName, keyFingerprint, err := contract.getIdentityBinding(stub, identity)
+	if err == nil {
+		sessionPair, _ := generateUserSessionDBPair(stub, userName, keyFingerprint, identity)
+		deletePair(stub, sessionPair)
+	}
+
+	identityPair, _ := generateIdentityDBPair(stub, identity, "", "")
+	deletePair(stub, identityPair)
+
+	return shim.Success([]byte("Logout successful!"))
+}
+
+// logs out every client identity that logged in with the key, so that no session outlives its revocation
+func (contract *Contract) endKeySessions(stub shim.ChaincodeStubInterface, userName string, keyFingerprint string) error {
+	sessionsIterator, err := stub.GetStateByPartialCompositeKey("index-UserSession", []string{userName, keyFingerprint})
+	if err != nil {
+		return errors.New("Could not find the sessions of key " + keyFingerprint)
+	}
+	defer sessionsIterator.Close()
+
+	for sessionsIterator.HasNext() {
+		sessionString, err := sessionsIterator.Next()
+		if err != nil {
+			return errors.New("Could not proceed to next session")
+		}
+
+		structuredSessionData := map[string]string{}
+		err = json.Unmarshal(sessionString.Value, &structuredSessionData)
+		if err != nil {
+			return errors.New("Could not unmarshal session")
+		}
+
+		identity := UserIdentity{MSPID: structuredSessionData["mspID"], ID: structuredSessionData["identityID"]}
+		identityPair, _ := generateIdentityDBPair(stub, identity, userName, keyFingerprint)
+		deletePair(stub, identityPair)
+
+		sessionPair, _ := generateUserSessionDBPair(stub, userName, keyFingerprint, identity)
+		deletePair(stub, sessionPair)
+	}
+
+	return nil
+}
+
+// replaces the public key stored in the user document, which is keyed by its public key
+func (contract *Contract) updateUserDocumentKey(stub shim.ChaincodeStubInterface, userInfo UserPublicInfo, publicKey string) {
+	oldUserPair, _ := generateUserDBPair(stub, User{PublicInfo: userInfo})
+	deletePair(stub, oldUserPair)
+
+	user := User{PublicInfo: UserPublicInfo{Name: userInfo.Name, PublicKey: publicKey, EmailHash: userInfo.EmailHash}}
 
 	userPair, _ := generateUserDBPair(stub, user)
 	applyPair(stub, userPair)
+}
+
+func (contract *Contract) changePublicKey(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// publicKey, optional:revocationReason
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) < 1 || len(args) > 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 or 2.")
+	}
+
+	revocationReason := ""
+	if len(args) == 2 {
+		revocationReason = args[1]
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	userKeys, err := contract.getUserKeys(stub, loggedInUser)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// The key stored in the user document is replaced, other device keys stay active
+	primaryFingerprint, _ := PublicKeyFingerprint(loggedInUser.PublicKey)
+	label := DefaultUserKeyLabel
+	for _, userKey := range userKeys {
+		if userKey.Fingerprint == primaryFingerprint && !userKey.IsRevoked() {
+			label = userKey.Label
+		}
+	}
+
+	newKey, err := CreateNewUserKey(loggedInUser.Name, label, args[0], currentTime.AsTime())
+	if err != nil {
+		return shim.Error("Invalid public key: " + err.Error())
+	}
+
+	// Revoke the replaced key, keeping it in the history
+	for _, userKey := range userKeys {
+		if userKey.Fingerprint == newKey.Fingerprint {
+			return shim.Error("This public key has already been used by user " + loggedInUser.Name + "!")
+		}
+		if userKey.Fingerprint == primaryFingerprint && !userKey.IsRevoked() {
+			userKey.Revoke(currentTime.AsTime(), revocationReason)
+			userKeyPair, _ := generateUserKeyDBPair(stub, userKey)
+			applyPair(stub, userKeyPair)
+
+			if err := contract.endKeySessions(stub, loggedInUser.Name, userKey.Fingerprint); err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+	}
+
+	newKeyPair, _ := generateUserKeyDBPair(stub, newKey)
+	applyPair(stub, newKeyPair)
+
+	contract.updateUserDocumentKey(stub, loggedInUser, args[0])
+
+	return shim.Success([]byte("Public key changed for user " + loggedInUser.Name))
+}
+
+func (contract *Contract) addUserKey(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// label, publicKey
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2.")
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	newKey, err := CreateNewUserKey(loggedInUser.Name, args[0], args[1], currentTime.AsTime())
+	if err != nil {
+		return shim.Error("Invalid public key: " + err.Error())
+	}
+
+	userKeys, err := contract.getUserKeys(stub, loggedInUser)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	for _, userKey := range userKeys {
+		if userKey.Fingerprint == newKey.Fingerprint {
+			return shim.Error("This public key has already been used by user " + loggedInUser.Name + "!")
+		}
+		if userKey.Label == newKey.Label && !userKey.IsRevoked() {
+			return shim.Error("User " + loggedInUser.Name + " already has an active key labelled " + newKey.Label + "!")
+		}
+	}
+
+	newKeyPair, _ := generateUserKeyDBPair(stub, newKey)
+	applyPair(stub, newKeyPair)
+
+	return shim.Success([]byte("Key " + newKey.Fingerprint + " has been added for user " + loggedInUser.Name))
+}
+
+func (contract *Contract) removeUserKey(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// fingerprint, optional:revocationReason
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) < 1 || len(args) > 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 or 2.")
+	}
+
+	revocationReason := ""
+	if len(args) == 2 {
+		revocationReason = args[1]
+	}
+
+	userKeys, err := contract.getUserKeys(stub, loggedInUser)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	activeKeys := ActiveUserKeys(userKeys)
+
+	var removedKey UserKey
+	var remainingKey UserKey
+	for _, userKey := range activeKeys {
+		if userKey.Fingerprint == args[0] {
+			removedKey = userKey
+		} else {
+			remainingKey = userKey
+		}
+	}
+
+	if removedKey.Fingerprint == "" {
+		return shim.Error("User " + loggedInUser.Name + " has no active key " + args[0] + "!")
+	}
+	if remainingKey.Fingerprint == "" {
+		return shim.Error("The last active key of user " + loggedInUser.Name + " cannot be removed!")
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	removedKey.Revoke(currentTime.AsTime(), revocationReason)
+	removedKeyPair, _ := generateUserKeyDBPair(stub, removedKey)
+	applyPair(stub, removedKeyPair)
+
+	if err := contract.endKeySessions(stub, loggedInUser.Name, removedKey.Fingerprint); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Keep an active key in the user document
+	if removedKey.PublicKey == loggedInUser.PublicKey {
+		contract.updateUserDocumentKey(stub, loggedInUser, remainingKey.PublicKey)
+	}
+
+	return shim.Success([]byte("Key " + removedKey.Fingerprint + " has been removed for user " + loggedInUser.Name))
+}
+
+func (contract *Contract) addNewRepo(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repo, or no argument and the repo under "repo" in the transient map to store it
+	// in the private data collection of the caller's organization
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	identity, err := getCallerIdentity(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	var repo Repository
+	if len(args) == 0 {
+		transientMap, err := stub.GetTransient()
+		if err != nil || transientMap["repo"] == nil {
+			return shim.Error("Transient map does not contain repo")
+		}
+
+		repo, err = UnmarshalRepo(string(transientMap["repo"]), currentTime.AsTime())
+		if err != nil {
+			return shim.Error("Repo is invalid!")
+		}
+		repo.PrivateCollection = GetOrgCollection(identity.MSPID)
+	} else {
+		// the content of private repos must not be recorded in the transaction arguments
+		var requestedRepo Repository
+		_ = json.Unmarshal([]byte(args[0]), &requestedRepo)
+		if requestedRepo.IsPrivate() {
+			return shim.Error("Private repos must be passed under \"repo\" in the transient map")
+		}
+
+		repo, err = UnmarshalRepo(args[0], currentTime.AsTime())
+		if err != nil {
+			return shim.Error("Repo is invalid!")
+		}
+	}
+
+	// checking that the creator is whom they claim to be
+	if loggedInUser.Name != repo.Author {
+		return shim.Error("Repo creator is not the signing user")
+	}
+
+	userKeys, err := contract.getUserKeys(stub, loggedInUser)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// verify the signatures of the initial commits
+	for branchName, branch := range repo.Branches {
+		for hash, commit := range branch.Commits {
+			if !commit.IsSigned() && repo.RequireSignedCommits {
+				return shim.Error("Commit " + hash + " is not signed but repo " + repo.Name + " requires signed commits!")
+			}
+			if err := commit.VerifyPushedSignature(userKeys, repo.CurrentTime); err != nil {
+				return shim.Error(err.Error())
+			}
+			commit.AuthorID = loggedInUser.Name
+			repo.Branches[branchName].Commits[hash] = commit
+		}
+	}
+
+	// check if repo already exists, a redirect left behind by a transfer or a rename does not keep the name taken
+	if contract.repoStoredAt(stub, repo.Author, repo.Name) {
+		return shim.Error("Repo already exists")
+	}
+
+	// writes to the repo need the endorsement of its creator's organization by default
+	if len(repo.EndorsingOrgs) == 0 {
+		repo.EndorsingOrgs = []string{identity.MSPID}
+	}
+
+	repoPairs, _ := generateRepoDBPair(stub, repo)
+	applyPairs(stub, repoPairs)
+
+	accessPairs, _ := generateRepoUserAccessesDBPair(stub, repo)
+	applyPairs(stub, accessPairs)
+
+	branchPairs, _ := generateRepoBranchesDBPair(stub, repo)
+	applyPairs(stub, branchPairs)
+
+	protectionPairs, _ := generateRepoBranchProtectionsDBPair(stub, repo)
+	applyPairs(stub, protectionPairs)
+
+	tagProtectionPairs, _ := generateRepoTagProtectionsDBPair(stub, repo)
+	applyPairs(stub, tagProtectionPairs)
+
+	branchCommitPairs, _ := generateRepoBranchesCommitsDBPair(stub, repo)
+	applyPairs(stub, branchCommitPairs)
+
+	for _, branch := range repo.Branches {
+		storeCommitsPII(stub, repo, branch.CommitList())
+	}
+
+	err = applyRepoEndorsementPolicies(stub, repo)
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the repo! " + err.Error())
+	}
+
+	return shim.Success([]byte("The repo has been added successfully to the blockchain."))
+}
+
+func (contract *Contract) renameRepo(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, newRepoName
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityAdminister) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to rename this repo")
+	}
+
+	if args[2] == "" || args[2] == repo.Name {
+		return shim.Error("Invalid repo name " + args[2] + "!")
+	}
+
+	// the new name may redirect to the repo itself when it was renamed away from it
+	existingRepo, err := contract.getRepoInstance(stub, []string{repo.Author, args[2]})
+	if err == nil && (existingRepo.Author != repo.Author || existingRepo.Name != repo.Name) {
+		return shim.Error(repo.Author + " already has a repo named " + args[2])
+	}
+
+	oldName := repo.Name
+
+	// the repo is moved as it is stored, its commits are not pushed again
+	oldDocuments, _ := generateRepoDocumentsDBPair(stub, repo)
+	oldRecords, err := getRepoRecordPairs(stub, repo)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	repo.UpdateRepoName(args[2])
+
+	err = moveRepo(stub, oldDocuments, oldRecords, repo.Author, oldName, repo)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success([]byte("Repo " + oldName + " has been renamed to " + repo.Name))
+}
+
+func (contract *Contract) deleteRepo(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityAdminister) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to delete this repo")
+	}
+
+	// Delete the records kept for the repo so that a repo created under the same name does not inherit them
+	recordPairs, err := getRepoRecordPairs(stub, repo)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	deletePairs(stub, recordPairs)
+
+	// Delete commits, branches, access, then repo in this order
+	documentPairs, _ := generateRepoDocumentsDBPair(stub, repo)
+	deletePairs(stub, documentPairs)
+
+	return shim.Success([]byte("The repo has been deleted successfully from the blockchain."))
+}
+
+func (contract *Contract) addNewBranch(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, branchBinary (under "branch" in the transient map for private repos)
+	return contract.addBranch(stub, args, false)
+}
+
+func (contract *Contract) addNewRootBranch(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, branchBinary (under "branch" in the transient map for private repos)
+	// Commits new to the repo may have no parent and start a new history, e.g. for orphan branches
+	return contract.addBranch(stub, args, true)
+}
+
+func (contract *Contract) addBranch(stub shim.ChaincodeStubInterface, args []string, allowNewRoot bool) peer.Response {
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	// generate Repo & check validation
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	branchPayload, err := getRepoPayload(stub, repo, args, 2, "branch")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	repoBranch, err := UnmarshalBranch(branchPayload)
+	if err != nil {
+		return shim.Error("RepoBranch is invalid!")
+	}
+
+	// check authorization
+	isAuthorized := repo.CanPush(loggedInUser.Name, repoBranch.Name)
+	if !isAuthorized {
+		return shim.Error("User is not authorized to edit this repo")
+	}
+
+	valid, err := repo.ValidBranch(repoBranch)
+	if err != nil || !valid {
+		return shim.Error("RepoBranch could not be added!")
+	}
+
+	userKeys, err := contract.getUserKeys(stub, loggedInUser)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	repoBranch, err = repo.VerifyBranchCommits(repoBranch, loggedInUser.Name, userKeys)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = repo.ValidBranchCommits(repoBranch, allowNewRoot)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = repoBranch.ResolveHead()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = contract.checkPushKeyEpoch(stub, repo, repoBranch.CommitList())
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = repo.CheckBranchPush(repoBranch.Name, loggedInUser.Name, repoBranch.CommitList(), false)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	branchPair, _ := generateRepoBranchDBPair(stub, repo, repoBranch)
+	applyPair(stub, branchPair)
+
+	commitsPairs, _ := generateRepoBranchesCommitsDBPairUsingBranch(stub, repo, repoBranch)
+	applyPairs(stub, commitsPairs)
+
+	storeCommitsPII(stub, repo, repoBranch.CommitList())
+
+	entry := newReflogEntry(stub, repo, repoBranch.Name, ReflogPush, "", repoBranch.Head, loggedInUser.Name)
+	reflogPair := storeReflogEntry(stub, repo, entry)
+
+	err = applyEndorsementPolicy(stub, repo, repoBranch.Name, append(commitsPairs, branchPair, reflogPair))
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the branch! " + err.Error())
+	}
+
+	return shim.Success([]byte("The branch has been added successfully to its corresponding repo!"))
+}
+
+func (contract *Contract) renameBranch(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, branchName, newBranchName
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	// generate Repo & check validation
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	// check authorization
+	isAuthorized := repo.Can(loggedInUser.Name, CapabilityManageBranches)
+	if !isAuthorized {
+		return shim.Error("User is not authorized to edit this repo")
+	}
+
+	if !repo.BranchExists(args[2]) {
+		return shim.Error("Requested branch does not exist in the repo")
+	}
+
+	branch := repo.Branches[args[2]]
+
+	err = repo.CheckBranchRename(branch.Name)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = repo.CheckBranchPush(args[3], loggedInUser.Name, branch.CommitList(), false)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	_, err = repo.UpdateBranchName(branch, args[3])
+	if err != nil {
+		return shim.Error("Unable to rename branch!")
+	}
+
+	// Delete branch pairs
+	commitsPairs, _ := generateRepoBranchesCommitsDBPairUsingBranch(stub, repo, branch)
+	deletePairs(stub, commitsPairs)
+
+	branchPair, _ := generateRepoBranchDBPair(stub, repo, branch)
+	deletePair(stub, branchPair)
+
+	newBranch := repo.Branches[args[3]]
+	// Add pairs for branch with new name
+	newBranchPair, _ := generateRepoBranchDBPair(stub, repo, newBranch)
+	applyPair(stub, newBranchPair)
+
+	newCommitsPairs, _ := generateRepoBranchesCommitsDBPairUsingBranch(stub, repo, newBranch)
+	applyPairs(stub, newCommitsPairs)
+
+	entry := newReflogEntry(stub, repo, newBranch.Name, ReflogRename, branch.Head, newBranch.Head, loggedInUser.Name)
+	entry.OldBranchName = branch.Name
+	reflogPair := storeReflogEntry(stub, repo, entry)
+
+	err = applyEndorsementPolicy(stub, repo, newBranch.Name, append(newCommitsPairs, newBranchPair, reflogPair))
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the branch! " + err.Error())
+	}
+
+	return shim.Success([]byte("The branch has been renamed in its corresponding repo!"))
+}
+
+func (contract *Contract) deleteBranch(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, branchName
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	// generate Repo & check validation
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	// check authorization
+	isAuthorized := repo.Can(loggedInUser.Name, CapabilityManageBranches)
+	if !isAuthorized {
+		return shim.Error("User is not authorized to edit this repo")
+	}
+
+	if args[2] == DefaultBranchName {
+		return shim.Error("main branch cannot be deleted!")
+	}
+
+	if !repo.BranchExists(args[2]) {
+		return shim.Error("Requested branch does not exist in the repo")
+	}
+
+	branch := repo.Branches[args[2]]
+
+	err = repo.CheckBranchDeletion(branch.Name)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	deleted, err := repo.DeleteBranch(branch.Name)
+	if !deleted || err != nil {
+		return shim.Error("Could not delete branch " + branch.Name)
+	}
+
+	// Delete commits
+	commitsPairs, _ := generateRepoBranchesCommitsDBPairUsingBranch(stub, repo, branch)
+	deletePairs(stub, commitsPairs)
+
+	// Delete branch
+	branchPair, _ := generateRepoBranchDBPair(stub, repo, branch)
+	deletePair(stub, branchPair)
+
+	// Keep the deleted commits in the reflog so that the branch can be restored
+	entry := newReflogEntry(stub, repo, branch.Name, ReflogDelete, branch.Head, "", loggedInUser.Name)
+	entry.DroppedCommits = branch.CommitList()
+	storeReflogEntry(stub, repo, entry)
+
+	return shim.Success([]byte("The branch has been deleted from its corresponding repo!"))
+}
+
+func (contract *Contract) pushOneCommit(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, branchName, commitBinary, optional:expectedHead
+	// (under "commit" and "expectedHead" in the transient map for private repos)
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	// generate Repo & check validation
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	commitPayload, err := getRepoPayload(stub, repo, args, 3, "commit")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	var commit Commit
+	err = json.Unmarshal([]byte(commitPayload), &commit)
+	if err != nil {
+		return shim.Error("Could not unmarshal commit!")
+	}
+
+	return contract.pushCommits(stub, args, loggedInUser, repo, []Commit{commit}, false)
+}
+
+func (contract *Contract) pushMultipleCommits(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, branchName, listCommits, optional:expectedHead
+	// (under "commits" and "expectedHead" in the transient map for private repos)
+	return contract.pushCommitList(stub, args, false)
+}
+
+func (contract *Contract) pushNewRootCommits(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, branchName, listCommits, optional:expectedHead
+	// (under "commits" and "expectedHead" in the transient map for private repos)
+	// The first commit may have no parent and start a new history, e.g. for orphan branches,
+	// which must be merged into the history of a branch that is not empty
+	return contract.pushCommitList(stub, args, true)
+}
+
+func (contract *Contract) pushCommitList(stub shim.ChaincodeStubInterface, args []string, allowNewRoot bool) peer.Response {
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	// generate Repo & check validation
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	commitsPayload, err := getRepoPayload(stub, repo, args, 3, "commits")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	var commitsToAdd []Commit
+	err = json.Unmarshal([]byte(commitsPayload), &commitsToAdd)
+	if err != nil {
+		return shim.Error("Push is invalid!")
+	}
+
+	return contract.pushCommits(stub, args, loggedInUser, repo, commitsToAdd, allowNewRoot)
+}
+
+// pushes the commits of every push function, after they have been read from the arguments
+func (contract *Contract) pushCommits(stub shim.ChaincodeStubInterface, args []string, loggedInUser UserPublicInfo, repo Repository, commitsToAdd []Commit, allowNewRoot bool) peer.Response {
+	expectedHead, err := getRepoOptionalArg(stub, repo, args, 4, "expectedHead")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// check authorization
+	isAuthorized := repo.CanPush(loggedInUser.Name, args[2])
+	if !isAuthorized {
+		return shim.Error("User is not authorized to edit this repo")
+	}
+
+	if len(commitsToAdd) < 1 {
+		return shim.Error("Could not find any commits")
+	}
+
+	userKeys, err := contract.getUserKeys(stub, loggedInUser)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	commitsToAdd, err = repo.VerifyCommits(commitsToAdd, loggedInUser.Name, userKeys)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = contract.checkPushKeyEpoch(stub, repo, commitsToAdd)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = repo.CheckBranchPush(args[2], loggedInUser.Name, commitsToAdd, false)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	var newBranch Branch
+	if !repo.BranchExists(args[2]) {
+		newBranch, _ = CreateNewBranch(args[2], nil)
+		repo.AddBranch(newBranch, false)
+	} else {
+		newBranch = repo.Branches[args[2]]
+	}
+
+	err = repo.PushCommits(commitsToAdd, newBranch.Name, expectedHead, allowNewRoot)
+	if err != nil {
+		return shim.Error("Commits could not be added! " + err.Error())
+	}
+
+	repoPairs, _ := generateRepoDBPair(stub, repo)
+	applyPairs(stub, repoPairs)
+
+	// the branch is stored on every push as its head moves
+	branchPair, _ := generateRepoBranchDBPair(stub, repo, repo.Branches[newBranch.Name])
+	applyPair(stub, branchPair)
+
+	push := Push{newBranch.Name, commitsToAdd}
+
+	commitsPairs, _ := generateRepoBranchesCommitsDBPairUsingPush(stub, repo, push)
+	applyPairs(stub, commitsPairs)
+
+	storeCommitsPII(stub, repo, commitsToAdd)
+
+	entry := newReflogEntry(stub, repo, newBranch.Name, ReflogPush, newBranch.Head, repo.Branches[newBranch.Name].Head, loggedInUser.Name)
+	reflogPair := storeReflogEntry(stub, repo, entry)
+
+	err = applyEndorsementPolicy(stub, repo, newBranch.Name, append(commitsPairs, branchPair, reflogPair))
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the pushed commits! " + err.Error())
+	}
+
+	return shim.Success([]byte("The commits have been added successfully to the blockchain"))
+}
+
+func (contract *Contract) updateRepoUserAccess(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, authorized, userAccess, optional:expiresAt (RFC 3339)
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) < 4 || len(args) > 5 {
+		return shim.Error("Incorrect number of arguments. Expecting 4 or 5.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	access, err := strconv.Atoi(args[3])
+	if err != nil {
+		return shim.Error("could not parse access")
+	}
+
+	var expiresAt time.Time
+	if len(args) == 5 && args[4] != "" {
+		expiresAt, err = time.Parse(time.RFC3339, args[4])
+		if err != nil {
+			return shim.Error("could not parse access expiry")
+		}
+	}
+
+	// access granted to a team requires the team to exist
+	if organization, teamName, isTeam := ParseTeamPrincipal(args[2]); isTeam {
+		if _, err := contract.getTeam(stub, organization, teamName); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	accessTimestamp, err := stub.GetTxTimestamp()
+
+	readersBefore := repo.GetUsersWithCapability(CapabilityRead)
+
+	if repo.UpdateAccess(args[2], UserAccess(access), loggedInUser.Name, accessTimestamp.AsTime(), expiresAt) {
+		// revoked readers must not be able to decrypt content pushed from now on
+		rotated := repo.RotateKeyEpochOnRevocation(readersBefore)
+
+		repoPairs, _ := generateRepoDBPair(stub, repo)
+		applyPairs(stub, repoPairs)
+		pair, _ := generateRepoUserAccessDBPair(stub, repo.Author, repo.Name, repo.LastAccessLog())
+		applyPair(stub, pair)
+
+		if rotated {
+			return shim.Success([]byte("Access to the repo has been updated successfully! The repo moved to key epoch " + strconv.Itoa(repo.KeyEpoch) + ", whose key must be distributed before the next push"))
+		}
+		return shim.Success([]byte("Access to the repo has been updated successfully!"))
+	}
+
+	return shim.Error("UserAccess was not set! Your access type does not permit you to do the required task")
+}
+
+func (contract *Contract) updateRepoRequireSignedCommits(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, requireSignedCommits
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityManageSettings) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to change the settings of this repo")
+	}
+
+	requireSignedCommits, err := strconv.ParseBool(args[2])
+	if err != nil {
+		return shim.Error("could not parse requireSignedCommits")
+	}
+
+	repo.RequireSignedCommits = requireSignedCommits
+
+	repoPairs, _ := generateRepoDBPair(stub, repo)
+	applyPairs(stub, repoPairs)
+
+	return shim.Success([]byte("Signed commits requirement of the repo has been updated successfully!"))
+}
+
+func (contract *Contract) createTeam(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// organization, teamName
+	// the organization is created along with its first team, owned by the creator
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2.")
+	}
+
+	if strings.Contains(args[0], "/") || args[0] == "" || args[1] == "" {
+		return shim.Error("Invalid organization or team name!")
+	}
+
+	organization, err := contract.getOrganization(stub, args[0])
+	if err != nil {
+		// organizations and users share the namespace of repo authors
+		if _, failMessage := contract.getUserPublicInfo(stub, args[0]); failMessage.Message == "" {
+			return shim.Error("A user named " + args[0] + " already exists!")
+		}
+
+		organization, _ = CreateNewOrganization(args[0], loggedInUser.Name)
+		organizationPair, _ := generateOrganizationDBPair(stub, organization)
+		applyPair(stub, organizationPair)
+	}
+
+	if !organization.IsOwner(loggedInUser.Name) {
+		return shim.Error("User " + loggedInUser.Name + " is not an owner of organization " + args[0])
+	}
+
+	if _, err := contract.getTeam(stub, args[0], args[1]); err == nil {
+		return shim.Error("Team " + TeamPrincipal(args[0], args[1]) + " already exists!")
+	}
+
+	team, _ := CreateNewTeam(args[0], args[1])
+
+	teamPair, _ := generateTeamDBPair(stub, team)
+	applyPair(stub, teamPair)
+
+	return shim.Success([]byte("Team " + team.Principal() + " has been created!"))
+}
+
+func (contract *Contract) addTeamMember(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// organization, teamName, userName
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	organization, err := contract.getOrganization(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !organization.IsOwner(loggedInUser.Name) {
+		return shim.Error("User " + loggedInUser.Name + " is not an owner of organization " + args[0])
+	}
+
+	team, err := contract.getTeam(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if _, failMessage := contract.getUserPublicInfo(stub, args[2]); failMessage.Message != "" {
+		return shim.Error("User " + args[2] + " does not exist!")
+	}
+
+	if !team.AddMember(args[2]) {
+		return shim.Error("User " + args[2] + " is already a member of team " + team.Principal())
+	}
+
+	teamPair, _ := generateTeamDBPair(stub, team)
+	applyPair(stub, teamPair)
+
+	return shim.Success([]byte("User " + args[2] + " has been added to team " + team.Principal()))
+}
+
+func (contract *Contract) removeTeamMember(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// organization, teamName, userName
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	organization, err := contract.getOrganization(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !organization.IsOwner(loggedInUser.Name) {
+		return shim.Error("User " + loggedInUser.Name + " is not an owner of organization " + args[0])
+	}
+
+	team, err := contract.getTeam(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !team.RemoveMember(args[2]) {
+		return shim.Error("User " + args[2] + " is not a member of team " + team.Principal())
+	}
+
+	repos, err := contract.getPrincipalRepos(stub, team.Principal())
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	for _, repo := range repos {
+		readersBefore := repo.GetUsersWithCapability(CapabilityRead)
+		repo.AddTeam(team)
+
+		// The repos the team owns must keep at least one owner
+		if len(repo.GetUsersWithAccess(OwnerAccess)) == 0 {
+			return shim.Error("User " + args[2] + " is the last owner of repo " + repo.Name + " through team " + team.Principal())
+		}
+
+		// the removed member must not be able to decrypt content pushed from now on
+		if repo.RotateKeyEpochOnRevocation(readersBefore) {
+			repoPairs, _ := generateRepoDBPair(stub, repo)
+			applyPairs(stub, repoPairs)
+		}
+	}
+
+	teamPair, _ := generateTeamDBPair(stub, team)
+	applyPair(stub, teamPair)
+
+	return shim.Success([]byte("User " + args[2] + " has been removed from team " + team.Principal()))
+}
+
+func (contract *Contract) setBranchProtection(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, branchProtectionRule
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	rule, err := UnmarshalBranchProtectionRule(args[2])
+	if err != nil {
+		return shim.Error("Branch protection rule is invalid! " + err.Error())
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.CanProtectBranch(loggedInUser.Name, rule) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to protect branches of this repo")
+	}
+
+	// endorsing organizations are only changed through updateRepoEndorsementPolicy
+	rule.EndorsingOrgs = repo.BranchProtections[rule.Pattern].EndorsingOrgs
+
+	repo.SetBranchProtection(rule)
+
+	protectionPair, _ := generateRepoBranchProtectionDBPair(stub, repo.Author, repo.Name, rule)
+	applyPair(stub, protectionPair)
+
+	return shim.Success([]byte("Branch protection rule " + rule.Pattern + " has been set!"))
+}
+
+func (contract *Contract) removeBranchProtection(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, pattern
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityManageSettings) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to protect branches of this repo")
+	}
+
+	rule, exist := repo.BranchProtections[args[2]]
+	if !exist {
+		return shim.Error("Branch protection rule " + args[2] + " does not exist")
+	}
+
+	if !repo.CanProtectBranch(loggedInUser.Name, rule) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to remove an owner-only rule of this repo")
+	}
+
+	if len(rule.EndorsingOrgs) > 0 && !repo.Can(loggedInUser.Name, CapabilityAdminister) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to change the endorsement policy of this repo")
+	}
+
+	protectionPair, _ := generateRepoBranchProtectionDBPair(stub, repo.Author, repo.Name, rule)
+	deletePair(stub, protectionPair)
+
+	if len(rule.EndorsingOrgs) > 0 {
+		delete(repo.BranchProtections, rule.Pattern)
+		err = applyRepoEndorsementPolicies(stub, repo)
+		if err != nil {
+			return shim.Error("Could not set the endorsement policy of the repo! " + err.Error())
+		}
+	}
+
+	return shim.Success([]byte("Branch protection rule " + rule.Pattern + " has been removed!"))
+}
+
+func (contract *Contract) updateRepoVisibility(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, visibility
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	visibility := RepoVisibility(args[2])
+	if !visibility.IsValid() {
+		return shim.Error("Visibility must be one of private, internal or public")
+	}
+
+	accessTimestamp, _ := stub.GetTxTimestamp()
+
+	if repo.UpdateVisibility(visibility, loggedInUser.Name, accessTimestamp.AsTime()) {
+		repoPairs, _ := generateRepoDBPair(stub, repo)
+		applyPairs(stub, repoPairs)
+		pair, _ := generateRepoUserAccessDBPair(stub, repo.Author, repo.Name, repo.LastAccessLog())
+		applyPair(stub, pair)
+
+		return shim.Success([]byte("Visibility of the repo has been updated successfully!"))
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityManageSettings) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to change the settings of this repo")
+	}
+	return shim.Error("Visibility was not set! The repo is already " + string(repo.Visibility))
+}
+
+func (contract *Contract) requestRepoAccess(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, userAccess
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	access, err := strconv.Atoi(args[2])
+	if err != nil || UserAccess(access).Level() == 0 {
+		return shim.Error("could not parse access")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if repo.GetUserAccess(loggedInUser.Name).Level() >= UserAccess(access).Level() {
+		return shim.Error("User " + loggedInUser.Name + " already has the requested access")
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	request, _ := CreateNewAccessRequest(stub.GetTxID(), repo.Author, repo.Name, AccessRequestKindRequest, loggedInUser.Name, loggedInUser.Name, UserAccess(access), currentTime.AsTime())
+
+	requestPair, _ := generateAccessRequestDBPair(stub, request)
+	applyPair(stub, requestPair)
+
+	return shim.Success([]byte(request.ID))
+}
+
+func (contract *Contract) inviteUser(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, invitee, userAccess
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 4.")
+	}
+
+	access, err := strconv.Atoi(args[3])
+	if err != nil || UserAccess(access).Level() == 0 {
+		return shim.Error("could not parse access")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.CanGrant(loggedInUser.Name, args[2], UserAccess(access)) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to invite users to this repo")
+	}
+
+	if args[2] == loggedInUser.Name {
+		return shim.Error("A user cannot invite themselves")
+	}
+
+	if _, failMessage := contract.getUserPublicInfo(stub, args[2]); failMessage.Message != "" {
+		return shim.Error("User " + args[2] + " does not exist!")
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	invitation, _ := CreateNewAccessRequest(stub.GetTxID(), repo.Author, repo.Name, AccessRequestKindInvitation, loggedInUser.Name, args[2], UserAccess(access), currentTime.AsTime())
+
+	invitationPair, _ := generateAccessRequestDBPair(stub, invitation)
+	applyPair(stub, invitationPair)
+
+	return shim.Success([]byte(invitation.ID))
+}
+
+// decides on a pending access request or invitation and records the outcome in the access logs
+func (contract *Contract) resolveAccessRequest(stub shim.ChaincodeStubInterface, args []string, kind AccessRequestKind, status AccessRequestStatus) peer.Response {
+	// repoAuthor, repoName, requestID
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	request, err := contract.getAccessRequest(stub, repo.Author, repo.Name, args[2])
+	if err != nil || request.Kind != kind {
+		return shim.Error("Access " + string(kind) + " " + args[2] + " does not exist")
+	}
+
+	if !request.IsPending() {
+		return shim.Error("Access " + string(kind) + " " + args[2] + " has already been " + string(request.Status))
+	}
+
+	if !request.CanBeResolvedBy(loggedInUser.Name, &repo) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to resolve access " + string(kind) + " " + args[2])
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	request.Resolve(status, loggedInUser.Name, currentTime.AsTime())
+
+	if !repo.RecordAccessRequestOutcome(request) {
+		return shim.Error("UserAccess was not set! The requested access could not be granted")
+	}
+
+	requestPair, _ := generateAccessRequestDBPair(stub, request)
+	applyPair(stub, requestPair)
+
+	repoPairs, _ := generateRepoDBPair(stub, repo)
+	applyPairs(stub, repoPairs)
+	pair, _ := generateRepoUserAccessDBPair(stub, repo.Author, repo.Name, repo.LastAccessLog())
+	applyPair(stub, pair)
+
+	return shim.Success([]byte("Access " + string(kind) + " " + request.ID + " has been " + string(status) + "!"))
+}
+
+func (contract *Contract) approveAccessRequest(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	return contract.resolveAccessRequest(stub, args, AccessRequestKindRequest, AccessRequestApproved)
+}
+
+func (contract *Contract) denyAccessRequest(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	return contract.resolveAccessRequest(stub, args, AccessRequestKindRequest, AccessRequestDenied)
+}
+
+func (contract *Contract) acceptInvitation(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	return contract.resolveAccessRequest(stub, args, AccessRequestKindInvitation, AccessRequestAccepted)
+}
+
+func (contract *Contract) declineInvitation(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	return contract.resolveAccessRequest(stub, args, AccessRequestKindInvitation, AccessRequestDeclined)
+}
+
+func (contract *Contract) transferRepo(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, newAuthor (a user or an organization)
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityAdminister) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to transfer this repo")
+	}
+
+	if args[2] == repo.Author {
+		return shim.Error("Repo " + repo.Name + " already belongs to " + args[2])
+	}
+
+	_, failMessage := contract.getUserPublicInfo(stub, args[2])
+	_, organizationErr := contract.getOrganization(stub, args[2])
+	if failMessage.Message != "" && organizationErr != nil {
+		return shim.Error("No user or organization named " + args[2] + " exists!")
+	}
+
+	if contract.repoStoredAt(stub, args[2], repo.Name) {
+		return shim.Error(args[2] + " already has a repo named " + repo.Name)
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	transfer, _ := CreateNewRepoTransfer(repo.Author, repo.Name, args[2], loggedInUser.Name, currentTime.AsTime())
+
+	transferPair, _ := generateRepoTransferDBPair(stub, transfer)
+	applyPair(stub, transferPair)
+
+	return shim.Success([]byte("Transfer of repo " + repo.Name + " to " + args[2] + " is awaiting acceptance"))
+}
+
+// checks if the mentioned user can accept a transfer to newAuthor, being either that user
+// or an owner of that organization
+func (contract *Contract) canReceiveRepo(stub shim.ChaincodeStubInterface, user string, newAuthor string) bool {
+	if user == newAuthor {
+		return true
+	}
+
+	organization, err := contract.getOrganization(stub, newAuthor)
+	return err == nil && organization.IsOwner(user)
+}
+
+func (contract *Contract) acceptRepoTransfer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, optional:previousAuthorAccess (ReadAccess when not given)
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 or 3.")
+	}
+
+	previousAuthorAccess := ReadAccess
+	if len(args) == 3 && args[2] != "" {
+		access, err := strconv.Atoi(args[2])
+		if err != nil {
+			return shim.Error("could not parse access")
+		}
+		previousAuthorAccess = UserAccess(access)
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	transfer, err := contract.getRepoTransfer(stub, repo.Author, repo.Name)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !contract.canReceiveRepo(stub, loggedInUser.Name, transfer.NewAuthor) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to accept the transfer to " + transfer.NewAuthor)
+	}
+
+	if contract.repoStoredAt(stub, transfer.NewAuthor, repo.Name) {
+		return shim.Error(transfer.NewAuthor + " already has a repo named " + repo.Name)
+	}
+
+	oldAuthor := repo.Author
+
+	transferPair, _ := generateRepoTransferDBPair(stub, transfer)
+	deletePair(stub, transferPair)
+
+	// Reflog entries, pull requests, reviews, tags, key envelopes and other records move along with the repo
+	oldDocuments, _ := generateRepoDocumentsDBPair(stub, repo)
+	oldRecords, err := getRepoRecordPairs(stub, repo)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	// the accepted transfer is not carried over
+	oldRecords = slices.DeleteFunc(oldRecords, func(pair LedgerPair) bool {
+		return pair.key == transferPair.key
+	})
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	readersBefore := repo.GetUsersWithCapability(CapabilityRead)
+
+	if !repo.Transfer(transfer.NewAuthor, transfer.Requester, currentTime.AsTime(), previousAuthorAccess) {
+		return shim.Error("Repo could not be transferred! " + transfer.Requester + " is no longer an owner of the repo, or the access of the previous author is invalid")
+	}
+
+	// the previous author or the owners of the previous organization may no longer read the repo
+	repo.RotateKeyEpochOnRevocation(readersBefore)
+
+	err = moveRepo(stub, oldDocuments, oldRecords, oldAuthor, repo.Name, repo)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success([]byte("Repo " + repo.Name + " has been transferred to " + repo.Author))
+}
+
+func (contract *Contract) declineRepoTransfer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName
+	// declines a transfer as its receiver, or cancels it as an owner of the repo
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	transfer, err := contract.getRepoTransfer(stub, repo.Author, repo.Name)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityAdminister) && !contract.canReceiveRepo(stub, loggedInUser.Name, transfer.NewAuthor) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to decline this transfer")
+	}
+
+	transferPair, _ := generateRepoTransferDBPair(stub, transfer)
+	deletePair(stub, transferPair)
+
+	return shim.Success([]byte("Transfer of repo " + repo.Name + " to " + transfer.NewAuthor + " has been declined"))
+}
+
+func (contract *Contract) updateRepoRecoveryQuorum(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, quorum (0 for a majority of the members allowed to recover ownership)
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	// only owners set the quorum, since admins take part in ownership recoveries
+	if !repo.Can(loggedInUser.Name, CapabilityAdminister) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to change the ownership recovery quorum of this repo")
+	}
+
+	quorum, err := strconv.Atoi(args[2])
+	if err != nil || quorum < 0 {
+		return shim.Error("could not parse quorum")
+	}
+
+	repo.OwnershipRecoveryQuorum = quorum
+
+	repoPairs, _ := generateRepoDBPair(stub, repo)
+	applyPairs(stub, repoPairs)
+
+	return shim.Success([]byte("Ownership recovery quorum of the repo has been updated successfully!"))
+}
+
+// stores an ownership recovery along with the access log recording its latest step
+func (contract *Contract) storeOwnershipRecoveryStep(stub shim.ChaincodeStubInterface, repo Repository, recovery OwnershipRecovery) {
+	recoveryPair, _ := generateOwnershipRecoveryDBPair(stub, recovery)
+	applyPair(stub, recoveryPair)
+
+	repoPairs, _ := generateRepoDBPair(stub, repo)
+	applyPairs(stub, repoPairs)
+	pair, _ := generateRepoUserAccessDBPair(stub, repo.Author, repo.Name, repo.LastAccessLog())
+	applyPair(stub, pair)
+}
+
+func (contract *Contract) proposeOwnershipRecovery(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, newOwner
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityRecoverOwnership) {
+		return shim.Error("Only maintainers and admins can propose an ownership recovery")
+	}
+
+	if !repo.Can(args[2], CapabilityRecoverOwnership) {
+		return shim.Error("Ownership can only be restored to a maintainer or an admin")
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	recovery, _ := CreateNewOwnershipRecovery(stub.GetTxID(), repo.Author, repo.Name, args[2], loggedInUser.Name, currentTime.AsTime())
+
+	repo.RecordOwnershipRecoveryStep(recovery, loggedInUser.Name, OwnershipRecoveryProposed, currentTime.AsTime())
+	contract.storeOwnershipRecoveryStep(stub, repo, recovery)
+
+	return shim.Success([]byte(recovery.ID))
+}
+
+func (contract *Contract) approveOwnershipRecovery(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, recoveryID
+	// approves the recovery, and restores ownership once the quorum and the delay are reached
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	recovery, err := contract.getOwnershipRecovery(stub, repo.Author, repo.Name, args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !recovery.IsPending() {
+		return shim.Error("Ownership recovery " + recovery.ID + " is no longer pending")
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityRecoverOwnership) {
+		return shim.Error("Only maintainers and admins can approve an ownership recovery")
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	approved := recovery.Approve(loggedInUser.Name)
+
+	if repo.RecordOwnershipRecoveryStep(recovery, loggedInUser.Name, OwnershipRecovered, currentTime.AsTime()) {
+		recovery.Status = OwnershipRecovered
+		// the keys of the owner who lost access may be compromised
+		repo.RotateKeyEpoch()
+		contract.storeOwnershipRecoveryStep(stub, repo, recovery)
+		return shim.Success([]byte("Ownership of the repo has been restored to " + recovery.NewOwner))
+	}
+
+	if !approved {
+		return shim.Error("User " + loggedInUser.Name + " already approved ownership recovery " + recovery.ID + ", which awaits more approvals or its delay")
+	}
+
+	repo.RecordOwnershipRecoveryStep(recovery, loggedInUser.Name, OwnershipRecoveryApproved, currentTime.AsTime())
+	contract.storeOwnershipRecoveryStep(stub, repo, recovery)
+
+	return shim.Success([]byte("Ownership recovery " + recovery.ID + " has been approved"))
+}
+
+func (contract *Contract) cancelOwnershipRecovery(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, recoveryID
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityAdminister) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to cancel an ownership recovery")
+	}
+
+	recovery, err := contract.getOwnershipRecovery(stub, repo.Author, repo.Name, args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !recovery.IsPending() {
+		return shim.Error("Ownership recovery " + recovery.ID + " is no longer pending")
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	recovery.Status = OwnershipRecoveryCancelled
+	repo.RecordOwnershipRecoveryStep(recovery, loggedInUser.Name, OwnershipRecoveryCancelled, currentTime.AsTime())
+	contract.storeOwnershipRecoveryStep(stub, repo, recovery)
+
+	return shim.Success([]byte("Ownership recovery " + recovery.ID + " has been cancelled"))
+}
+
+func (contract *Contract) updateRepoEndorsementPolicy(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, endorsingOrgs (JSON list of MSP IDs), optional:pattern
+	// without a pattern, sets the organizations endorsing writes to any key of the repo,
+	// otherwise the additional organizations endorsing writes to branches protected by the rule
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 && len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 3 or 4.")
+	}
+
+	var orgs []string
+	err = json.Unmarshal([]byte(args[2]), &orgs)
+	if err != nil {
+		return shim.Error("could not parse endorsing organizations")
+	}
+	for _, org := range orgs {
+		if org == "" {
+			return shim.Error("could not parse endorsing organizations")
+		}
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityAdminister) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to change the endorsement policy of this repo")
+	}
+
+	if len(args) == 3 {
+		if len(orgs) == 0 {
+			return shim.Error("At least one organization must endorse writes to the repo")
+		}
+		if repo.IsPrivate() && !slices.Contains(orgs, repo.GetPrivateOrg()) {
+			return shim.Error("Only peers of " + repo.GetPrivateOrg() + " hold the content of private repo " + repo.Name + " and must endorse writes to it")
+		}
+		repo.EndorsingOrgs = orgs
+
+		repoPairs, _ := generateRepoDBPair(stub, repo)
+		applyPairs(stub, repoPairs)
+	} else {
+		rule, exist := repo.BranchProtections[args[3]]
+		if !exist {
+			return shim.Error("Branch protection rule " + args[3] + " does not exist")
+		}
+		rule.EndorsingOrgs = orgs
+		repo.SetBranchProtection(rule)
+
+		protectionPair, _ := generateRepoBranchProtectionDBPair(stub, repo.Author, repo.Name, rule)
+		applyPair(stub, protectionPair)
+	}
+
+	err = applyRepoEndorsementPolicies(stub, repo)
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the repo! " + err.Error())
+	}
+
+	return shim.Success([]byte("Endorsement policy of the repo has been updated successfully!"))
+}
+
+func (contract *Contract) eraseUserData(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// no arguments, erases the personal data of the logged in user
+	// the data is purged from the collection of personal data, and from the peers' private data history
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	// the commits authored with the user's email no longer carry it
+	email := contract.getUserEmail(stub, loggedInUser.Name)
+	if email != "" {
+		queryString, _ := json.Marshal(map[string]interface{}{"selector": map[string]interface{}{"docName": "commitPII", "email": email}})
+		commitsIterator, err := stub.GetPrivateDataQueryResult(UserPIICollection, string(queryString))
+		if err != nil {
+			return shim.Error("Could not find the commits of user " + loggedInUser.Name + ": " + err.Error())
+		}
+		defer commitsIterator.Close()
+
+		for commitsIterator.HasNext() {
+			record, err := commitsIterator.Next()
+			if err != nil {
+				return shim.Error("Could not proceed to next commit of user " + loggedInUser.Name)
+			}
+			err = stub.PurgePrivateData(UserPIICollection, record.Key)
+			if err != nil {
+				return shim.Error("Could not erase the personal data of user " + loggedInUser.Name + ": " + err.Error())
+			}
+		}
+	}
+
+	piiKey, _ := getUserPIIKey(stub, loggedInUser.Name)
+	err = stub.PurgePrivateData(UserPIICollection, piiKey)
+	if err != nil {
+		return shim.Error("Could not erase the personal data of user " + loggedInUser.Name + ": " + err.Error())
+	}
+
+	// without its salt, the hash can no longer be matched, but it is removed from the user document too
+	user := User{PublicInfo: UserPublicInfo{Name: loggedInUser.Name, PublicKey: loggedInUser.PublicKey}}
+
+	userPair, _ := generateUserDBPair(stub, user)
+	applyPair(stub, userPair)
+
+	return shim.Success([]byte("Personal data of user " + loggedInUser.Name + " has been erased"))
+}
+
+// checks that the pushed commits are encrypted under the current key epoch of the repo,
+// whose key must have been distributed to the readers
+func (contract *Contract) checkPushKeyEpoch(stub shim.ChaincodeStubInterface, repo Repository, commits []Commit) error {
+	if !repo.IsEncrypted() {
+		return nil
+	}
+
+	envelopes, err := contract.getRepoKeyEnvelopes(stub, repo, repo.KeyEpoch)
+	if err != nil {
+		return err
+	}
+
+	return repo.CheckPushKeyEpoch(envelopes, commits)
+}
+
+func (contract *Contract) distributeRepoKey(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, epoch, keyEnvelopes (JSON list of {user, keyFingerprint, wrappedKey})
+	// the current epoch adds envelopes for new readers, the next epoch rotates the content key
+	// and must wrap it for every reader, as must the first distribution of an epoch started by a revocation
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 4.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityManageAccess) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to distribute the content key of this repo")
+	}
+
+	if !repo.IsEncrypted() {
+		return shim.Error("Encryption of repo " + repo.Name + " has not been enabled, its owners can enable it with enableRepoEncryption")
+	}
+
+	epoch, err := strconv.Atoi(args[2])
+	if err != nil || epoch < 1 {
+		return shim.Error("could not parse key epoch")
+	}
+
+	if epoch == repo.KeyEpoch+1 {
+		currentEnvelopes, err := contract.getRepoKeyEnvelopes(stub, repo, repo.KeyEpoch)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if len(currentEnvelopes) == 0 {
+			return shim.Error("Key epoch " + strconv.Itoa(repo.KeyEpoch) + " of repo " + repo.Name + " has not been distributed yet")
+		}
+	} else if epoch != repo.KeyEpoch {
+		return shim.Error("Key epoch must be the current epoch " + strconv.Itoa(repo.KeyEpoch) + " or the next one")
+	}
+
+	count, err := contract.storeRepoKeyEnvelopes(stub, repo, epoch, args[3], loggedInUser.Name)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if epoch != repo.KeyEpoch {
+		repo.KeyEpoch = epoch
+
+		repoPairs, _ := generateRepoDBPair(stub, repo)
+		applyPairs(stub, repoPairs)
+	}
+
+	return shim.Success([]byte("Content key of epoch " + strconv.Itoa(epoch) + " has been distributed to " + strconv.Itoa(count) + " readers"))
+}
+
+// validates and stores the content key of the epoch wrapped for readers of the repo, returns the number of envelopes stored.
+// The first distribution of an epoch must wrap the key for every reader
+func (contract *Contract) storeRepoKeyEnvelopes(stub shim.ChaincodeStubInterface, repo Repository, epoch int, envelopesPayload string, wrappedBy string) (int, error) {
+	existingEnvelopes, err := contract.getRepoKeyEnvelopes(stub, repo, epoch)
+	if err != nil {
+		return 0, err
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	envelopes, err := UnmarshalKeyEnvelopes(envelopesPayload, &repo, epoch, wrappedBy, currentTime.AsTime())
+	if err != nil {
+		return 0, err
+	}
+
+	for _, envelope := range envelopes {
+		if !repo.CanRead(envelope.User) {
+			return 0, errors.New("User " + envelope.User + " does not have read access to " + repo.Name)
+		}
+
+		userInfo, failMessage := contract.getUserPublicInfo(stub, envelope.User)
+		if failMessage.Message != "" {
+			return 0, errors.New("User " + envelope.User + " does not exist!")
+		}
+		userKeys, err := contract.getUserKeys(stub, userInfo)
+		if err != nil {
+			return 0, err
+		}
+		if err := envelope.CheckWrappingKey(ActiveUserKeys(userKeys)); err != nil {
+			return 0, err
+		}
+	}
+
+	// every reader must be able to decrypt content encrypted under a new key
+	if len(existingEnvelopes) == 0 {
+		if missing := repo.GetReadersWithoutEnvelope(envelopes); len(missing) > 0 {
+			return 0, errors.New("The content key must be wrapped for every reader, missing: " + strings.Join(missing, ", "))
+		}
+	}
+
+	for _, envelope := range envelopes {
+		envelopePair, _ := generateRepoKeyEnvelopeDBPair(stub, envelope)
+		applyPair(stub, envelopePair)
+	}
+
+	return len(envelopes), nil
+}
+
+func (contract *Contract) enableRepoEncryption(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, keyEnvelopes (JSON list of {user, keyFingerprint, wrappedKey})
+	// starts key epoch 1, whose content key must be wrapped for every reader
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.Can(loggedInUser.Name, CapabilityAdminister) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to enable the encryption of this repo")
+	}
+
+	if repo.Visibility != PrivateVisibility {
+		return shim.Error("Only the content of private repos can be encrypted")
+	}
+
+	if repo.IsEncrypted() {
+		return shim.Error("Repo " + repo.Name + " is already encrypted")
+	}
+
+	count, err := contract.storeRepoKeyEnvelopes(stub, repo, 1, args[2], loggedInUser.Name)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	repo.KeyEpoch = 1
+
+	repoPairs, _ := generateRepoDBPair(stub, repo)
+	applyPairs(stub, repoPairs)
+
+	return shim.Success([]byte("Encryption of the repo has been enabled, the content key of epoch 1 has been distributed to " + strconv.Itoa(count) + " readers"))
+}
+
+func (contract *Contract) forcePushBranch(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, branchName, newHead, listCommits, optional:expectedHead
+	// (under "commits" and "expectedHead" in the transient map for private repos)
+	// the branch is replaced with the history of newHead, which is either an existing commit or one of the pushed commits
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) < 4 {
+		return shim.Error("Incorrect number of arguments. Expecting at least 4.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.IsOwner(loggedInUser.Name) {
+		return shim.Error("Only owners can force-push to a branch")
+	}
+
+	if !repo.BranchExists(args[2]) {
+		return shim.Error("Requested branch does not exist in the repo")
+	}
+
+	commitsPayload, err := getRepoPayload(stub, repo, args, 4, "commits")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	var commitsToAdd []Commit
+	err = json.Unmarshal([]byte(commitsPayload), &commitsToAdd)
+	if err != nil {
+		return shim.Error("Push is invalid!")
+	}
+
+	expectedHead, err := getRepoOptionalArg(stub, repo, args, 5, "expectedHead")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	userKeys, err := contract.getUserKeys(stub, loggedInUser)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	commitsToAdd, err = repo.VerifyCommits(commitsToAdd, loggedInUser.Name, userKeys)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = contract.checkPushKeyEpoch(stub, repo, commitsToAdd)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = repo.CheckBranchPush(args[2], loggedInUser.Name, commitsToAdd, false)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	oldBranch := repo.Branches[args[2]]
+
+	dropped, err := repo.ForcePushCommits(commitsToAdd, oldBranch.Name, args[3], expectedHead)
+	if err != nil {
+		return shim.Error("Branch could not be force-pushed! " + err.Error())
+	}
+
+	repoPairs, _ := generateRepoDBPair(stub, repo)
+	applyPairs(stub, repoPairs)
+
+	branchPairs := storeBranchHistory(stub, repo, oldBranch, dropped)
+
+	entry := newReflogEntry(stub, repo, oldBranch.Name, ReflogForcePush, oldBranch.Head, args[3], loggedInUser.Name)
+	entry.DroppedCommits = dropped
+	reflogPair := storeReflogEntry(stub, repo, entry)
+
+	err = applyEndorsementPolicy(stub, repo, oldBranch.Name, append(branchPairs, reflogPair))
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the pushed commits! " + err.Error())
+	}
+
+	return shim.Success([]byte("Branch " + oldBranch.Name + " now points at " + args[3]))
+}
+
+func (contract *Contract) restoreBranchHead(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, branchName, reflogEntryID
+	// points the branch back at the head it had before the reflog entry, restoring it if it was deleted
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 4.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.IsOwner(loggedInUser.Name) {
+		return shim.Error("Only owners can restore the head of a branch")
+	}
+
+	entries, err := contract.getReflog(stub, repo, args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// commits dropped from the branch are only kept in its reflog
+	knownCommits := repo.GetCommits()
+	var restoredEntry ReflogEntry
+	for _, entry := range entries {
+		if entry.ID == args[3] {
+			restoredEntry = entry
+		}
+		for _, commit := range entry.DroppedCommits {
+			if _, exist := knownCommits[commit.Hash]; !exist {
+				knownCommits[commit.Hash] = commit
+			}
+		}
+	}
+
+	if restoredEntry.ID == "" {
+		return shim.Error("Reflog entry " + args[3] + " does not exist for branch " + args[2])
+	}
+
+	if restoredEntry.OldHead == "" {
+		return shim.Error("Branch " + args[2] + " had no head before reflog entry " + args[3])
+	}
+
+	oldBranch, exist := repo.Branches[args[2]]
+	if !exist {
+		oldBranch, _ = CreateNewBranch(args[2], nil)
+	}
+
+	// the commits the restore brings back into the branch must satisfy its protection rules like pushed ones
+	restoredCommits, err := oldBranch.MissingCommits(restoredEntry.OldHead, knownCommits)
+	if err != nil {
+		return shim.Error("Could not restore the head of the branch! " + err.Error())
+	}
+
+	err = repo.CheckBranchPush(oldBranch.Name, loggedInUser.Name, restoredCommits, false)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	dropped, err := repo.SetBranchHead(oldBranch.Name, restoredEntry.OldHead, knownCommits)
+	if err != nil {
+		return shim.Error("Could not restore the head of the branch! " + err.Error())
+	}
+
+	repoPairs, _ := generateRepoDBPair(stub, repo)
+	applyPairs(stub, repoPairs)
+
+	branchPairs := storeBranchHistory(stub, repo, oldBranch, dropped)
+
+	entry := newReflogEntry(stub, repo, oldBranch.Name, ReflogRestore, oldBranch.Head, restoredEntry.OldHead, loggedInUser.Name)
+	entry.DroppedCommits = dropped
+	reflogPair := storeReflogEntry(stub, repo, entry)
+
+	err = applyEndorsementPolicy(stub, repo, oldBranch.Name, append(branchPairs, reflogPair))
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the branch! " + err.Error())
+	}
+
+	return shim.Success([]byte("Branch " + oldBranch.Name + " now points at " + restoredEntry.OldHead))
+}
+
+func (contract *Contract) mergeBranch(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, sourceBranch, targetBranch, mode ("fast-forward" or "merge-commit"),
+	// mergeCommit for the merge-commit mode (under "commit" in the transient map for private repos)
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) < 5 {
+		return shim.Error("Incorrect number of arguments. Expecting at least 5.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	// check authorization
+	isAuthorized := repo.CanPush(loggedInUser.Name, args[3])
+	if !isAuthorized {
+		return shim.Error("User is not authorized to edit this repo")
+	}
+
+	if !repo.BranchExists(args[2]) || !repo.BranchExists(args[3]) {
+		return shim.Error("Requested branch does not exist in the repo")
+	}
+
+	mode := MergeMode(args[4])
+	var mergeCommit Commit
+	if mode == MergeCommit {
+		commitPayload, err := getRepoPayload(stub, repo, args, 5, "commit")
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		err = json.Unmarshal([]byte(commitPayload), &mergeCommit)
+		if err != nil {
+			return shim.Error("Could not unmarshal commit!")
+		}
+
+		userKeys, err := contract.getUserKeys(stub, loggedInUser)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		verifiedCommits, err := repo.VerifyCommits([]Commit{mergeCommit}, loggedInUser.Name, userKeys)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		mergeCommit = verifiedCommits[0]
+
+		err = contract.checkPushKeyEpoch(stub, repo, verifiedCommits)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	target := repo.Branches[args[3]]
+
+	added, err := repo.MergeBranch(args[2], target.Name, mode, mergeCommit)
+	if err != nil {
+		return shim.Error("Branches could not be merged! " + err.Error())
+	}
+
+	// commits merged into the target branch must satisfy its protection rules as if they were pushed to it
+	err = repo.CheckBranchPush(target.Name, loggedInUser.Name, added, false)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	repoPairs, _ := generateRepoDBPair(stub, repo)
+	applyPairs(stub, repoPairs)
+
+	branchPairs := storeBranchHistory(stub, repo, target, nil)
+
+	newHead := repo.Branches[target.Name].Head
+	entry := newReflogEntry(stub, repo, target.Name, ReflogMerge, target.Head, newHead, loggedInUser.Name)
+	reflogPair := storeReflogEntry(stub, repo, entry)
+
+	err = applyEndorsementPolicy(stub, repo, target.Name, append(branchPairs, reflogPair))
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the merged commits! " + err.Error())
+	}
+
+	return shim.Success([]byte("Branch " + args[2] + " has been merged into " + target.Name + ", which now points at " + newHead))
+}
+
+// returns the repo and the branch a pull request merges from, which the user must be able to read.
+// The content of a private repo can only be proposed to a repo of the same private data collection.
+func (contract *Contract) getPullRequestSource(stub shim.ChaincodeStubInterface, repo Repository, user string, sourceAuthor string, sourceName string, sourceBranch string) (Repository, Branch, error) {
+	source := repo
+	if sourceAuthor != repo.Author || sourceName != repo.Name {
+		var err error
+		source, err = contract.getRepoInstance(stub, []string{sourceAuthor, sourceName})
+		if err != nil {
+			return source, Branch{}, errors.New("Source repo does not exist")
+		}
+	}
+
+	if !source.CanRead(user) {
+		return source, Branch{}, errors.New("User " + user + " cannot read repo " + source.Name)
+	}
+
+	if source.IsPrivate() && source.PrivateCollection != repo.PrivateCollection {
+		return source, Branch{}, errors.New("Private repo " + source.Name + " can only be merged into repos of " + source.GetPrivateOrg())
+	}
+
+	branch, exist := source.Branches[sourceBranch]
+	if !exist {
+		return source, branch, errors.New("Branch " + sourceBranch + " does not exist in repo " + source.Name)
+	}
+	if _, exist := branch.GetHead(); !exist {
+		return source, branch, errors.New("Branch " + sourceBranch + " has no commits")
+	}
+
+	return source, branch, nil
+}
+
+// stores a pull request and sets the endorsement policy of its target branch on it
+func storePullRequest(stub shim.ChaincodeStubInterface, repo Repository, pullRequest PullRequest) error {
+	pullRequestPair, _ := generatePullRequestDBPair(stub, repo, pullRequest)
+	applyPair(stub, pullRequestPair)
+
+	return applyEndorsementPolicy(stub, repo, pullRequest.TargetBranch, []LedgerPair{pullRequestPair})
+}
+
+func (contract *Contract) openPullRequest(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, targetBranch, sourceRepoAuthor, sourceRepoName, sourceBranch, title, optional:description
+	// (under "title" and "description" in the transient map for private repos)
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) < 6 || len(args) > 8 {
+		return shim.Error("Incorrect number of arguments. Expecting 6 to 8.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	if !repo.CanRead(loggedInUser.Name) {
+		return readAccessDenied(loggedInUser.Name, args[1])
+	}
+
+	if err := contract.checkCollectionMembership(stub, repo); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !repo.BranchExists(args[2]) {
+		return shim.Error("Requested branch does not exist in the repo")
+	}
+
+	if args[3] == repo.Author && args[4] == repo.Name && args[5] == args[2] {
+		return shim.Error("A branch cannot be merged into itself")
+	}
+
+	_, sourceBranch, err := contract.getPullRequestSource(stub, repo, loggedInUser.Name, args[3], args[4], args[5])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	title, err := getRepoPayload(stub, repo, args, 6, "title")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if title == "" {
+		return shim.Error("A pull request needs a title")
+	}
+
+	description, err := getRepoOptionalArg(stub, repo, args, 7, "description")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+
+	pullRequest, _ := CreateNewPullRequest(stub.GetTxID(), repo.Author, repo.Name, args[2], args[3], args[4], args[5],
+		loggedInUser.Name, title, description, sourceBranch.Head, currentTime.AsTime())
+
+	err = storePullRequest(stub, repo, pullRequest)
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the pull request! " + err.Error())
+	}
+
+	return shim.Success([]byte(pullRequest.ID))
+}
+
+func (contract *Contract) updatePullRequest(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, pullRequestID, optional:title, optional:description
+	// (under "title" and "description" in the transient map for private repos)
+	// records the current head of the source branch, and replaces the title and description when not empty
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) < 3 || len(args) > 5 {
+		return shim.Error("Incorrect number of arguments. Expecting 3 to 5.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	pullRequest, err := contract.getPullRequest(stub, repo, args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if pullRequest.Author != loggedInUser.Name && !repo.Can(loggedInUser.Name, CapabilityManageMetadata) {
+		return shim.Error("User is not authorized to update this pull request")
+	}
+
+	if !pullRequest.IsOpen() {
+		return shim.Error("Pull request " + pullRequest.ID + " is already " + string(pullRequest.State))
+	}
+
+	_, sourceBranch, err := contract.getPullRequestSource(stub, repo, loggedInUser.Name, pullRequest.SourceRepoAuthor, pullRequest.SourceRepoName, pullRequest.SourceBranch)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+	pullRequest.RecordHead(sourceBranch.Head, currentTime.AsTime())
+
+	title, err := getRepoOptionalArg(stub, repo, args, 3, "title")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if title != "" {
+		pullRequest.Title = title
+	}
+
+	description, err := getRepoOptionalArg(stub, repo, args, 4, "description")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if description != "" {
+		pullRequest.Description = description
+	}
+
+	err = storePullRequest(stub, repo, pullRequest)
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the pull request! " + err.Error())
+	}
+
+	return shim.Success([]byte("Pull request " + pullRequest.ID + " has been updated to " + pullRequest.Head()))
+}
+
+func (contract *Contract) closePullRequest(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, pullRequestID
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
+	}
+
+	pullRequest, err := contract.getPullRequest(stub, repo, args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if pullRequest.Author != loggedInUser.Name && !repo.Can(loggedInUser.Name, CapabilityManageMetadata) {
+		return shim.Error("User is not authorized to close this pull request")
+	}
+
+	if !pullRequest.IsOpen() {
+		return shim.Error("Pull request " + pullRequest.ID + " is already " + string(pullRequest.State))
+	}
+
+	currentTime, _ := stub.GetTxTimestamp()
+	pullRequest.Resolve(PullRequestClosed, loggedInUser.Name, currentTime.AsTime())
+
+	err = storePullRequest(stub, repo, pullRequest)
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the pull request! " + err.Error())
+	}
 
-	return shim.Success([]byte("User has successfully been created!"))
+	return shim.Success([]byte("Pull request " + pullRequest.ID + " has been closed"))
 }
 
-func (contract *Contract) logIn(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	// userName, privateKey
-	_, err := contract.getLoggedInUser(stub)
-	if err == nil {
-		return shim.Error("Another user is currently logged in. Please log out before trying to log in!")
+func (contract *Contract) mergePullRequest(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, pullRequestID, optional:mergeCommit (under "commit" in the transient map for private repos)
+	// the target branch is fast-forwarded when possible, otherwise the merge commit must have both heads as parents
+
+	loggedInUser, err := contract.getLoggedInUser(stub)
+	if err != nil {
+		return shim.Error("Please log in first!")
 	}
 
-	// userName, publicKey
-	// Check if user already exists
-	userPublicInfo, failMessage := contract.getUserPublicInfo(stub, args[0])
-	if failMessage.Message != "" {
-		return shim.Error("User " + args[0] + " does not exist!")
+	if len(args) != 3 && len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 3 or 4.")
 	}
-	if userPublicInfo.PublicKey != args[1] {
-		return shim.Error("Wrong public key provided for user " + args[0] + "!")
+
+	repo, err := contract.getRepoInstance(stub, args)
+	if err != nil {
+		return shim.Error("Repo does not exist")
 	}
 
-	marshaledUserPublicInfo, _ := json.Marshal(userPublicInfo)
+	pullRequest, err := contract.getPullRequest(stub, repo, args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-	// Store login info in state DB
-	stub.PutState("loggedInUser", marshaledUserPublicInfo)
+	// check authorization
+	isAuthorized := repo.CanPush(loggedInUser.Name, pullRequest.TargetBranch)
+	if !isAuthorized {
+		return shim.Error("User is not authorized to edit this repo")
+	}
 
-	return shim.Success([]byte("User " + userPublicInfo.Name + " has successfully logged in"))
-}
+	if !pullRequest.IsOpen() {
+		return shim.Error("Pull request " + pullRequest.ID + " is already " + string(pullRequest.State))
+	}
 
-func (contract *Contract) logOut(stub shim.ChaincodeStubInterface) peer.Response {
-	// Store login info in state DB
-	stub.DelState("loggedInUser")
+	if !repo.BranchExists(pullRequest.TargetBranch) {
+		return shim.Error("Target branch " + pullRequest.TargetBranch + " does not exist in the repo")
+	}
 
-	return shim.Success([]byte("Logout successful!"))
-}
+	source, sourceBranch, err := contract.getPullRequestSource(stub, repo, loggedInUser.Name, pullRequest.SourceRepoAuthor, pullRequest.SourceRepoName, pullRequest.SourceBranch)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-func (contract *Contract) changePublicKey(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	// publicKey
+	// only the changes the pull request was last updated to can be merged
+	if sourceBranch.Head != pullRequest.Head() {
+		return shim.Error("Branch " + sourceBranch.Name + " has moved since the pull request was last updated")
+	}
 
-	loggedInUser, err := contract.getLoggedInUser(stub)
+	reviews, err := contract.getReviews(stub, repo, pullRequest.ID)
 	if err != nil {
-		return shim.Error("Please log in first!")
+		return shim.Error(err.Error())
 	}
 
-	user := User{PublicInfo: UserPublicInfo{loggedInUser.Name, loggedInUser.Email, args[0]}}
-
-	userPair, _ := generateUserDBPair(stub, user)
-	applyPair(stub, userPair)
+	err = repo.CheckPullRequestApprovals(&pullRequest, reviews)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-	marshaledUserPublicInfo, _ := json.Marshal(user.PublicInfo)
+	target := repo.Branches[pullRequest.TargetBranch]
 
-	// Store login info in state DB
-	stub.PutState("loggedInUser", marshaledUserPublicInfo)
+	knownCommits := repo.GetCommits()
+	for hash, commit := range source.GetCommits() {
+		if _, exist := knownCommits[hash]; !exist {
+			knownCommits[hash] = commit
+		}
+	}
 
-	return shim.Success([]byte("Public key changed for user " + loggedInUser.Name))
-}
+	commitsToAdd, err := target.MissingCommits(sourceBranch.Head, knownCommits)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(commitsToAdd) == 0 {
+		return shim.Error("Branch " + sourceBranch.Name + " is already merged into " + target.Name)
+	}
 
-func (contract *Contract) addNewRepo(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	// repo
+	// the signatures of commits new to the repo are verified again against the keys of their authors,
+	// the records of a fork do not vouch for the repo
+	authorKeys := make(map[string][]UserKey)
+	for i, commit := range commitsToAdd {
+		if repo.CommitExists(commit.Hash) {
+			continue
+		}
+
+		commitsToAdd[i].Verified = false
+		if commit.IsSigned() {
+			keys, exist := authorKeys[commit.AuthorID]
+			if !exist {
+				userInfo, failMessage := contract.getUserPublicInfo(stub, commit.AuthorID)
+				if failMessage.Message != "" {
+					return shim.Error("Author " + commit.AuthorID + " of commit " + commit.Hash + " does not exist!")
+				}
+				keys, err = contract.getUserKeys(stub, userInfo)
+				if err != nil {
+					return shim.Error(err.Error())
+				}
+				authorKeys[commit.AuthorID] = keys
+			}
+
+			err = commitsToAdd[i].VerifySignature(keys)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+
+		if repo.RequireSignedCommits && !commitsToAdd[i].Verified {
+			return shim.Error("Commit " + commit.Hash + " is not signed but repo " + repo.Name + " requires signed commits!")
+		}
+	}
 
-	loggedInUser, err := contract.getLoggedInUser(stub)
+	mergeCommitPayload, err := getRepoOptionalArg(stub, repo, args, 3, "commit")
 	if err != nil {
-		return shim.Error("Please log in first!")
+		return shim.Error(err.Error())
 	}
 
-	currentTime, _ := stub.GetTxTimestamp()
+	fastForward := target.Head == "" || GetAncestors(sourceBranch.Head, knownCommits)[target.Head]
+	if !fastForward {
+		if mergeCommitPayload == "" {
+			return shim.Error("Branch " + target.Name + " cannot be fast-forwarded, a merge commit is needed")
+		}
+
+		var mergeCommit Commit
+		err = json.Unmarshal([]byte(mergeCommitPayload), &mergeCommit)
+		if err != nil {
+			return shim.Error("Could not unmarshal commit!")
+		}
+
+		if len(mergeCommit.ParentHashes) != 2 || !slices.Contains(mergeCommit.ParentHashes, target.Head) || !slices.Contains(mergeCommit.ParentHashes, sourceBranch.Head) {
+			return shim.Error("Parents of merge commit " + mergeCommit.Hash + " must be the heads of " + target.Name + " and " + sourceBranch.Name)
+		}
+
+		userKeys, err := contract.getUserKeys(stub, loggedInUser)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		verifiedCommits, err := repo.VerifyCommits([]Commit{mergeCommit}, loggedInUser.Name, userKeys)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		commitsToAdd = append(commitsToAdd, verifiedCommits[0])
+	}
 
-	repo, err := UnmarshalRepo(args[0], currentTime.AsTime())
+	err = contract.checkPushKeyEpoch(stub, repo, commitsToAdd)
 	if err != nil {
-		return shim.Error("Repo is invalid!")
+		return shim.Error(err.Error())
 	}
 
-	// checking that the creator is whom they claim to be
-	if loggedInUser.Name != repo.Author {
-		return shim.Error("Repo creator is not the signing user")
+	// the approvals of the pull request have been checked
+	err = repo.CheckBranchPush(target.Name, loggedInUser.Name, commitsToAdd, true)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
 
-	// check if repo already exists
-	repoArgsList := make([]string, 2)
-	repoArgsList[0] = repo.Author
-	repoArgsList[1] = repo.Name
-	_, err = contract.getRepoInstance(stub, repoArgsList)
-	if err == nil {
-		return shim.Error("Repo already exists")
+	// commits of a fork that are not part of the repo yet are pushed along with the merge
+	err = repo.PushCommits(commitsToAdd, target.Name, target.Head, false)
+	if err != nil {
+		return shim.Error("Pull request could not be merged! " + err.Error())
 	}
 
 	repoPairs, _ := generateRepoDBPair(stub, repo)
 	applyPairs(stub, repoPairs)
 
-	accessPairs, _ := generateRepoUserAccessesDBPair(stub, repo)
-	applyPairs(stub, accessPairs)
+	branchPairs := storeBranchHistory(stub, repo, target, nil)
 
-	branchPairs, _ := generateRepoBranchesDBPair(stub, repo)
-	applyPairs(stub, branchPairs)
+	newHead := repo.Branches[target.Name].Head
+	entry := newReflogEntry(stub, repo, target.Name, ReflogMerge, target.Head, newHead, loggedInUser.Name)
+	reflogPair := storeReflogEntry(stub, repo, entry)
 
-	branchCommitPairs, _ := generateRepoBranchesCommitsDBPair(stub, repo)
-	applyPairs(stub, branchCommitPairs)
+	err = applyEndorsementPolicy(stub, repo, target.Name, append(branchPairs, reflogPair))
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the merged commits! " + err.Error())
+	}
 
-	return shim.Success([]byte("The repo has been added successfully to the blockchain."))
+	currentTime, _ := stub.GetTxTimestamp()
+	pullRequest.Resolve(PullRequestMerged, loggedInUser.Name, currentTime.AsTime())
+
+	err = storePullRequest(stub, repo, pullRequest)
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the pull request! " + err.Error())
+	}
+
+	return shim.Success([]byte("Pull request " + pullRequest.ID + " has been merged into " + target.Name + ", which now points at " + newHead))
 }
 
-func (contract *Contract) renameRepo(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	// repoAuthor, repoName, newRepoName
+func (contract *Contract) submitReview(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, pullRequestID, kind ("approve", "request-changes" or "comment"), body,
+	// optional:signature, optional:signatureFormat
+	// (under "body", "signature" and "signatureFormat" in the transient map for private repos)
+	// the review applies to the head of the source branch the pull request was last updated to
 
 	loggedInUser, err := contract.getLoggedInUser(stub)
 	if err != nil {
 		return shim.Error("Please log in first!")
 	}
 
+	if len(args) < 4 || len(args) > 7 {
+		return shim.Error("Incorrect number of arguments. Expecting 4 to 7.")
+	}
+
 	repo, err := contract.getRepoInstance(stub, args)
 	if err != nil {
 		return shim.Error("Repo does not exist")
 	}
 
-	if !repo.IsOwner(loggedInUser.Name) {
-		return shim.Error("User " + loggedInUser.Name + " is not authorized to rename this repo")
+	if !repo.CanRead(loggedInUser.Name) {
+		return readAccessDenied(loggedInUser.Name, args[1])
 	}
 
-	contract.deleteRepo(stub, []string{repo.Author, repo.Name})
+	if err := contract.checkCollectionMembership(stub, repo); err != nil {
+		return shim.Error(err.Error())
+	}
 
-	repo.Name = args[2]
-	marshaledRepo, err := json.Marshal(repo)
+	pullRequest, err := contract.getPullRequest(stub, repo, args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-	contract.addNewRepo(stub, []string{string(marshaledRepo)})
+	if !pullRequest.IsOpen() {
+		return shim.Error("Pull request " + pullRequest.ID + " is already " + string(pullRequest.State))
+	}
 
-	return shim.Success([]byte("The repo has been successfully renamed from the blockchain."))
-}
+	kind := ReviewKind(args[3])
+	if !kind.IsValid() {
+		return shim.Error("Unknown review kind " + args[3])
+	}
 
-func (contract *Contract) deleteRepo(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	// repoAuthor, repoName
+	_, sourceBranch, err := contract.getPullRequestSource(stub, repo, loggedInUser.Name, pullRequest.SourceRepoAuthor, pullRequest.SourceRepoName, pullRequest.SourceBranch)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-	loggedInUser, err := contract.getLoggedInUser(stub)
+	if sourceBranch.Head != pullRequest.Head() {
+		return shim.Error("Branch " + sourceBranch.Name + " has moved since the pull request was last updated")
+	}
+
+	body, err := getRepoPayload(stub, repo, args, 4, "body")
 	if err != nil {
-		return shim.Error("Please log in first!")
+		return shim.Error(err.Error())
 	}
 
-	repo, err := contract.getRepoInstance(stub, args)
+	currentTime, _ := stub.GetTxTimestamp()
+
+	review, _ := CreateNewReview(stub.GetTxID(), pullRequest, loggedInUser.Name, kind, body, currentTime.AsTime())
+
+	review.Signature, err = getRepoOptionalArg(stub, repo, args, 5, "signature")
 	if err != nil {
-		return shim.Error("Repo does not exist")
+		return shim.Error(err.Error())
 	}
 
-	if !repo.IsOwner(loggedInUser.Name) {
-		return shim.Error("User " + loggedInUser.Name + " is not authorized to delete this repo")
+	review.SignatureFormat, err = getRepoOptionalArg(stub, repo, args, 6, "signatureFormat")
+	if err != nil {
+		return shim.Error(err.Error())
 	}
 
-	// Delete commits, branches, access, then repo in this order
-	branchCommitPairs, _ := generateRepoBranchesCommitsDBPair(stub, repo)
-	deletePairs(stub, branchCommitPairs)
+	userKeys, err := contract.getUserKeys(stub, loggedInUser)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-	branchPairs, _ := generateRepoBranchesDBPair(stub, repo)
-	deletePairs(stub, branchPairs)
+	err = review.VerifySignature(userKeys)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-	accessPairs, _ := generateRepoUserAccessesDBPair(stub, repo)
-	deletePairs(stub, accessPairs)
+	reviewPair, _ := generateReviewDBPair(stub, repo, review)
+	applyPair(stub, reviewPair)
 
-	repoPairs, _ := generateRepoDBPair(stub, repo)
-	deletePairs(stub, repoPairs)
+	err = applyEndorsementPolicy(stub, repo, pullRequest.TargetBranch, []LedgerPair{reviewPair})
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the review! " + err.Error())
+	}
 
-	return shim.Success([]byte("The repo has been deleted successfully from the blockchain."))
+	return shim.Success([]byte(review.ID))
 }
 
-func (contract *Contract) addNewBranch(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	// repoAuthor, repoName, branchBinary
+func (contract *Contract) updateRepoRequiredApprovals(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, requiredApprovals (0 to merge pull requests into protected branches without approvals)
 
 	loggedInUser, err := contract.getLoggedInUser(stub)
 	if err != nil {
 		return shim.Error("Please log in first!")
 	}
 
-	repoBranch, err := UnmarshalBranch(args[2])
-	if err != nil {
-		return shim.Error("RepoBranch is invalid!")
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
 	}
 
-	// generate Repo & check validation
 	repo, err := contract.getRepoInstance(stub, args)
 	if err != nil {
 		return shim.Error("Repo does not exist")
 	}
 
-	// check authorization
-	isAuthorized := repo.CanEdit(loggedInUser.Name)
-	if !isAuthorized {
-		return shim.Error("User is not authorized to edit this repo")
+	if !repo.Can(loggedInUser.Name, CapabilityManageSettings) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to change the settings of this repo")
 	}
 
-	valid, err := repo.ValidBranch(repoBranch)
-	if err != nil || !valid {
-		return shim.Error("RepoBranch could not be added!")
+	requiredApprovals, err := strconv.Atoi(args[2])
+	if err != nil || requiredApprovals < 0 {
+		return shim.Error("could not parse required approvals")
 	}
 
-	branchPair, _ := generateRepoBranchDBPair(stub, args[0], args[1], repoBranch)
-	applyPair(stub, branchPair)
+	repo.RequiredApprovals = requiredApprovals
 
-	commitsPairs, _ := generateRepoBranchesCommitsDBPairUsingBranch(stub, args[0], args[1], repoBranch)
-	applyPairs(stub, commitsPairs)
+	repoPairs, _ := generateRepoDBPair(stub, repo)
+	applyPairs(stub, repoPairs)
 
-	return shim.Success([]byte("The branch has been added successfully to its corresponding repo!"))
+	return shim.Success([]byte("Required approvals of the repo have been updated successfully!"))
 }
 
-func (contract *Contract) renameBranch(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	// repoAuthor, repoName, branchName, newBranchName
+func (contract *Contract) createTag(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, tagName, commitHash, optional:message, optional:signature, optional:signatureFormat
+	// (under "tagName", "commit", "message", "signature" and "signatureFormat" in the transient map for private repos)
+	// a message makes the tag annotated by the logged in user, only annotated tags can be signed
+	// an existing tag is moved to the commit unless its protection rules forbid it
 
 	loggedInUser, err := contract.getLoggedInUser(stub)
 	if err != nil {
 		return shim.Error("Please log in first!")
 	}
 
-	// generate Repo & check validation
+	if len(args) < 2 || len(args) > 7 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 to 7.")
+	}
+
 	repo, err := contract.getRepoInstance(stub, args)
 	if err != nil {
 		return shim.Error("Repo does not exist")
 	}
 
-	// check authorization
-	isAuthorized := repo.CanEdit(loggedInUser.Name)
-	if !isAuthorized {
-		return shim.Error("User is not authorized to edit this repo")
+	if !repo.Can(loggedInUser.Name, CapabilityManageTags) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to manage tags of this repo")
 	}
 
-	if !repo.BranchExists(args[2]) {
-		return shim.Error("Requested branch does not exist in the repo")
+	if err := contract.checkCollectionMembership(stub, repo); err != nil {
+		return shim.Error(err.Error())
 	}
 
-	branch := repo.Branches[args[2]]
-
-	_, err = repo.UpdateBranchName(branch, args[3])
+	tagName, err := getRepoPayload(stub, repo, args, 2, "tagName")
 	if err != nil {
-		return shim.Error("Unable to rename branch!")
+		return shim.Error(err.Error())
 	}
 
-	// Delete branch pairs
-	commitsPairs, _ := generateRepoBranchesCommitsDBPairUsingBranch(stub, args[0], args[1], branch)
-	deletePairs(stub, commitsPairs)
+	commitHash, err := getRepoPayload(stub, repo, args, 3, "commit")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-	branchPair, _ := generateRepoBranchDBPair(stub, args[0], args[1], branch)
-	deletePair(stub, branchPair)
+	if err := CheckTagName(tagName); err != nil {
+		return shim.Error(err.Error())
+	}
 
-	newBranch := repo.Branches[args[3]]
-	// Add pairs for branch with new name
-	newBranchPair, _ := generateRepoBranchDBPair(stub, args[0], args[1], newBranch)
-	applyPair(stub, newBranchPair)
+	if !repo.CommitExists(commitHash) {
+		return shim.Error("Commit " + commitHash + " does not exist in the repo")
+	}
 
-	newCommitsPairs, _ := generateRepoBranchesCommitsDBPairUsingBranch(stub, args[0], args[1], newBranch)
-	applyPairs(stub, newCommitsPairs)
+	oldTag, err := contract.getTag(stub, repo, tagName)
+	exists := err == nil
+	if exists && oldTag.Commit == commitHash {
+		return shim.Error("Tag " + tagName + " already points to commit " + commitHash)
+	}
 
-	return shim.Success([]byte("The branch has been renamed in its corresponding repo!"))
-}
+	err = repo.CheckTagUpdate(tagName, loggedInUser.Name, exists)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-func (contract *Contract) deleteBranch(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	// repoAuthor, repoName, branchName
+	currentTime, _ := stub.GetTxTimestamp()
 
-	loggedInUser, err := contract.getLoggedInUser(stub)
+	tag, err := CreateNewTag(tagName, repo.Author, repo.Name, commitHash, loggedInUser.Name, currentTime.AsTime())
 	if err != nil {
-		return shim.Error("Please log in first!")
+		return shim.Error(err.Error())
 	}
 
-	// generate Repo & check validation
-	repo, err := contract.getRepoInstance(stub, args)
+	message, err := getRepoOptionalArg(stub, repo, args, 4, "message")
 	if err != nil {
-		return shim.Error("Repo does not exist")
+		return shim.Error(err.Error())
 	}
-
-	// check authorization
-	isAuthorized := repo.CanEdit(loggedInUser.Name)
-	if !isAuthorized {
-		return shim.Error("User is not authorized to edit this repo")
+	if message != "" {
+		tag.Tagger = loggedInUser.Name
+		tag.Message = message
 	}
 
-	if args[2] == "main" {
-		return shim.Error("main branch cannot be deleted!")
+	tag.Signature, err = getRepoOptionalArg(stub, repo, args, 5, "signature")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if tag.Signature != "" && !tag.IsAnnotated() {
+		return shim.Error("Only annotated tags can be signed")
 	}
 
-	if !repo.BranchExists(args[2]) {
-		return shim.Error("Requested branch does not exist in the repo")
+	tag.SignatureFormat, err = getRepoOptionalArg(stub, repo, args, 6, "signatureFormat")
+	if err != nil {
+		return shim.Error(err.Error())
 	}
 
-	branch := repo.Branches[args[2]]
+	if tag.Signature != "" {
+		userKeys, err := contract.getUserKeys(stub, loggedInUser)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 
-	deleted, err := repo.DeleteBranch(branch.Name)
-	if !deleted || err != nil {
-		return shim.Error("Could not delete branch " + branch.Name)
+		err = tag.VerifySignature(userKeys)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 	}
 
-	// Delete commits
-	commitsPairs, _ := generateRepoBranchesCommitsDBPairUsingBranch(stub, args[0], args[1], branch)
-	deletePairs(stub, commitsPairs)
+	tagPair, _ := generateTagDBPair(stub, repo, tag)
+	applyPair(stub, tagPair)
 
-	// Delete branch
-	branchPair, _ := generateRepoBranchDBPair(stub, args[0], args[1], branch)
-	deletePair(stub, branchPair)
+	err = applyEndorsementPolicy(stub, repo, "", []LedgerPair{tagPair})
+	if err != nil {
+		return shim.Error("Could not set the endorsement policy of the tag! " + err.Error())
+	}
 
-	return shim.Success([]byte("The branch has been deleted from its corresponding repo!"))
+	return shim.Success([]byte("Tag " + tag.Name + " now points to commit " + tag.Commit))
 }
 
-func (contract *Contract) pushOneCommit(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	// repoAuthor, repoName, branchName, commitBinary
+func (contract *Contract) deleteTag(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, tagName (under "tagName" in the transient map for private repos)
 
 	loggedInUser, err := contract.getLoggedInUser(stub)
 	if err != nil {
 		return shim.Error("Please log in first!")
 	}
 
-	var commit Commit
-	err = json.Unmarshal([]byte(args[3]), &commit)
-	if err != nil {
-		shim.Error("Could not unmarshal commit!")
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 or 3.")
 	}
 
-	// generate Repo & check validation
 	repo, err := contract.getRepoInstance(stub, args)
 	if err != nil {
 		return shim.Error("Repo does not exist")
 	}
 
-	// check authorization
-	isAuthorized := repo.CanEdit(loggedInUser.Name)
-	if !isAuthorized {
-		return shim.Error("User is not authorized to edit this repo")
+	if !repo.Can(loggedInUser.Name, CapabilityManageTags) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to manage tags of this repo")
 	}
 
-	branchDidNotExist := !repo.BranchExists(args[2])
-	var newBranch Branch
-	if branchDidNotExist {
-		newBranch, _ := CreateNewBranch(args[2], nil)
-		branchDidNotExist, _ = repo.AddBranch(newBranch, false)
-	} else {
-		newBranch = repo.Branches[args[2]]
+	if err := contract.checkCollectionMembership(stub, repo); err != nil {
+		return shim.Error(err.Error())
 	}
 
-	valid, err := repo.AddCommit(commit, newBranch.Name, false)
-	if err != nil || !valid {
-		return shim.Error("Commit could not be added!")
+	tagName, err := getRepoPayload(stub, repo, args, 2, "tagName")
+	if err != nil {
+		return shim.Error(err.Error())
 	}
 
-	// Set the branch with the commit added to the repo
-	repo.Branches[newBranch.Name] = newBranch
-
-	repoPairs, _ := generateRepoDBPair(stub, repo)
-	applyPairs(stub, repoPairs)
-
-	if branchDidNotExist {
-		branchPair, _ := generateRepoBranchDBPair(stub, args[0], args[1], repo.Branches[newBranch.Name])
-		applyPair(stub, branchPair)
+	tag, err := contract.getTag(stub, repo, tagName)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
 
-	push := Push{newBranch.Name, []Commit{commit}}
+	err = repo.CheckTagDeletion(tag.Name, loggedInUser.Name)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-	commitsPairs, _ := generateRepoBranchesCommitsDBPairUsingPush(stub, args[0], args[1], push)
-	applyPairs(stub, commitsPairs)
+	tagPair, _ := generateTagDBPair(stub, repo, tag)
+	deletePair(stub, tagPair)
 
-	return shim.Success([]byte("The commits have been added successfully to the blockchain"))
+	return shim.Success([]byte("Tag " + tag.Name + " has been deleted!"))
 }
 
-func (contract *Contract) pushMultipleCommits(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	// repoAuthor, repoName, branchName, listCommits
+func (contract *Contract) setTagProtection(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, tagProtectionRule
 
 	loggedInUser, err := contract.getLoggedInUser(stub)
 	if err != nil {
 		return shim.Error("Please log in first!")
 	}
 
-	var commitsToAdd []Commit
-	err = json.Unmarshal([]byte(args[3]), &commitsToAdd)
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	rule, err := UnmarshalTagProtectionRule(args[2])
 	if err != nil {
-		return shim.Error("Push is invalid!")
+		return shim.Error("Tag protection rule is invalid! " + err.Error())
 	}
 
-	// generate Repo & check validation
 	repo, err := contract.getRepoInstance(stub, args)
 	if err != nil {
 		return shim.Error("Repo does not exist")
 	}
 
-	// check authorization
-	isAuthorized := repo.CanEdit(loggedInUser.Name)
-	if !isAuthorized {
-		return shim.Error("User is not authorized to edit this repo")
-	}
-
-	if len(commitsToAdd) < 1 {
-		return shim.Error("Could not find any commits")
-	}
-
-	branchDidNotExist := !repo.BranchExists(args[2])
-	var newBranch Branch
-	if branchDidNotExist {
-		newBranch, _ := CreateNewBranch(args[2], nil)
-		branchDidNotExist, _ = repo.AddBranch(newBranch, false)
-	} else {
-		newBranch = repo.Branches[args[2]]
-	}
-
-	valid, err := repo.AddCommits(commitsToAdd, newBranch.Name, false)
-	if err != nil || !valid {
-		return shim.Error("Commits could not be added! " + err.Error())
-	}
-
-	// Set the branch with the commits added to the repo
-	repo.Branches[newBranch.Name] = newBranch
-
-	repoPairs, _ := generateRepoDBPair(stub, repo)
-	applyPairs(stub, repoPairs)
-
-	if branchDidNotExist {
-		branchPair, _ := generateRepoBranchDBPair(stub, args[0], args[1], repo.Branches[newBranch.Name])
-		applyPair(stub, branchPair)
+	if !repo.CanProtectTag(loggedInUser.Name, rule) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to protect tags of this repo")
 	}
 
-	push := Push{newBranch.Name, commitsToAdd}
+	repo.SetTagProtection(rule)
 
-	commitsPairs, _ := generateRepoBranchesCommitsDBPairUsingPush(stub, args[0], args[1], push)
-	applyPairs(stub, commitsPairs)
+	protectionPair, _ := generateRepoTagProtectionDBPair(stub, repo.Author, repo.Name, rule)
+	applyPair(stub, protectionPair)
 
-	return shim.Success([]byte("The commits have been added successfully to the blockchain"))
+	return shim.Success([]byte("Tag protection rule " + rule.Pattern + " has been set!"))
 }
 
-func (contract *Contract) updateRepoUserAccess(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	// repoAuthor, repoName, authorized, userAccess
+func (contract *Contract) removeTagProtection(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// repoAuthor, repoName, pattern
 
 	loggedInUser, err := contract.getLoggedInUser(stub)
 	if err != nil {
 		return shim.Error("Please log in first!")
 	}
 
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
 	repo, err := contract.getRepoInstance(stub, args)
 	if err != nil {
 		return shim.Error("Repo does not exist")
 	}
 
-	access, err := strconv.Atoi(args[3])
-	if err != nil {
-		return shim.Error("could not parse access")
+	if !repo.Can(loggedInUser.Name, CapabilityManageSettings) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to protect tags of this repo")
 	}
 
-	accessTimestamp, err := stub.GetTxTimestamp()
-
-	if repo.UpdateAccess(args[2], UserAccess(access), loggedInUser.Name, accessTimestamp.AsTime()) {
-		repoPairs, _ := generateRepoDBPair(stub, repo)
-		applyPairs(stub, repoPairs)
-		pair, _ := generateRepoUserAccessDBPair(stub, args[0], args[1], args[2], args[3], loggedInUser.Name, accessTimestamp.AsTime())
-		applyPair(stub, pair)
+	rule, exist := repo.TagProtections[args[2]]
+	if !exist {
+		return shim.Error("Tag protection rule " + args[2] + " does not exist")
+	}
 
-		return shim.Success([]byte("Access to the repo has been updated successfully!"))
+	if !repo.CanProtectTag(loggedInUser.Name, rule) {
+		return shim.Error("User " + loggedInUser.Name + " is not authorized to remove an owner-only rule of this repo")
 	}
 
-	return shim.Error("UserAccess was not set! Your access type does not permit you to do the required task")
+	protectionPair, _ := generateRepoTagProtectionDBPair(stub, repo.Author, repo.Name, rule)
+	deletePair(stub, protectionPair)
+
+	return shim.Success([]byte("Tag protection rule " + rule.Pattern + " has been removed!"))
 }
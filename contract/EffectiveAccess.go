@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// This enum represents where the effective access of a principal comes from
+type AccessSource string
+
+const (
+	AccessSourceDirect       AccessSource = "direct"       // granted to the user
+	AccessSourceTeam         AccessSource = "team"         // granted to a team the user is a member of
+	AccessSourceOrganization AccessSource = "organization" // the user owns the organization owning the repo
+	AccessSourcePublic       AccessSource = "public"       // the repo is public
+	AccessSourceInternal     AccessSource = "internal"     // the repo is internal
+)
+
+// The current role of a principal in a repo, along with the grant it comes from.
+// Access granted by visibility is listed under the VisibilityLogPrincipal.
+type EffectiveAccess struct {
+	Principal  string       `json:"principal"`
+	UserAccess UserAccess   `json:"userAccess"`
+	Source     AccessSource `json:"source"`
+	Team       string       `json:"team,omitempty"` // set when the access comes from a team
+	GrantedBy  string       `json:"grantedBy,omitempty"`
+	GrantedAt  time.Time    `json:"grantedAt"`
+	ExpiresAt  time.Time    `json:"expiresAt"` // zero when the grant does not expire
+}
+
+// returns the current role of every user with access to the repo, sorted by principal,
+// followed by the access granted to everyone else by the visibility of the repo.
+// When several grants give a user the same role, direct grants come first, then team grants.
+func (repo *Repository) GetEffectiveAccesses() []EffectiveAccess {
+	accesses := make([]EffectiveAccess, 0)
+
+	users := repo.getUsersWhere(func(user string) bool {
+		return repo.GetUserAccess(user) != NoAccess
+	})
+	for _, user := range users {
+		accesses = append(accesses, repo.getEffectiveAccess(user))
+	}
+
+	if visibilityAccess, ok := repo.getVisibilityEffectiveAccess(); ok {
+		accesses = append(accesses, visibilityAccess)
+	}
+
+	return accesses
+}
+
+// returns the current role of the user along with the grant it comes from
+func (repo *Repository) getEffectiveAccess(user string) EffectiveAccess {
+	effectiveAccess := EffectiveAccess{Principal: user, UserAccess: NoAccess}
+
+	if visibilityAccess, ok := repo.getVisibilityEffectiveAccess(); ok && user != "" {
+		effectiveAccess = visibilityAccess
+		effectiveAccess.Principal = user
+	}
+
+	if repo.Organization != nil && repo.Organization.IsOwner(user) {
+		return EffectiveAccess{Principal: user, UserAccess: OwnerAccess, Source: AccessSourceOrganization}
+	}
+
+	teams := make([]string, 0)
+	for principal := range repo.Access {
+		if team, exist := repo.Teams[principal]; exist && team.HasMember(user) {
+			teams = append(teams, principal)
+		}
+	}
+	sort.Strings(teams)
+
+	for _, team := range teams {
+		if access := repo.getPrincipalAccess(team); access.Level() > effectiveAccess.UserAccess.Level() {
+			effectiveAccess = repo.getGrant(team, access)
+			effectiveAccess.Principal = user
+			effectiveAccess.Source = AccessSourceTeam
+			effectiveAccess.Team = team
+		}
+	}
+
+	if access := repo.getPrincipalAccess(user); access.Level() > 0 && access.Level() >= effectiveAccess.UserAccess.Level() {
+		effectiveAccess = repo.getGrant(user, access)
+		effectiveAccess.Source = AccessSourceDirect
+	}
+
+	return effectiveAccess
+}
+
+// returns the access granted directly to the principal, along with the access log that granted it
+func (repo *Repository) getGrant(principal string, access UserAccess) EffectiveAccess {
+	grant := EffectiveAccess{Principal: principal, UserAccess: access, ExpiresAt: repo.AccessExpiry[principal]}
+
+	for i := len(repo.AccessLogs) - 1; i >= 0; i-- {
+		accessLog := repo.AccessLogs[i]
+		if accessLog.Authorized != principal || accessLog.Visibility != "" {
+			continue
+		}
+		if accessLog.RequestStatus != "" && !accessLog.RequestStatus.Grants() {
+			continue
+		}
+		grant.GrantedBy = accessLog.Authorizer
+		grant.GrantedAt = accessLog.Timestamp
+		break
+	}
+
+	return grant
+}
+
+// returns the access granted to everyone by the visibility of the repo, if any
+func (repo *Repository) getVisibilityEffectiveAccess() (EffectiveAccess, bool) {
+	var source AccessSource
+	switch repo.Visibility {
+	case PublicVisibility:
+		source = AccessSourcePublic
+	case InternalVisibility:
+		source = AccessSourceInternal
+	default:
+		return EffectiveAccess{}, false
+	}
+
+	effectiveAccess := EffectiveAccess{Principal: VisibilityLogPrincipal, UserAccess: ReadAccess, Source: source}
+	for i := len(repo.AccessLogs) - 1; i >= 0; i-- {
+		if repo.AccessLogs[i].Visibility != "" {
+			effectiveAccess.GrantedBy = repo.AccessLogs[i].Authorizer
+			effectiveAccess.GrantedAt = repo.AccessLogs[i].Timestamp
+			break
+		}
+	}
+
+	return effectiveAccess, true
+}
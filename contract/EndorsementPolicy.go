@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+)
+
+// returns the MSP IDs of the organizations whose peers must endorse writes to the keys of the
+// mentioned branch, which are the repo's endorsing organizations along with those of the protection
+// rules matching the branch. An empty branchName returns those of the repo keys.
+func (repo *Repository) GetEndorsingOrgs(branchName string) []string {
+	orgs := make(map[string]bool)
+	for _, org := range repo.EndorsingOrgs {
+		orgs[org] = true
+	}
+
+	if branchName != "" {
+		for _, rule := range repo.GetBranchProtections(branchName) {
+			for _, org := range rule.EndorsingOrgs {
+				orgs[org] = true
+			}
+		}
+	}
+
+	list := make([]string, 0, len(orgs))
+	for org := range orgs {
+		list = append(list, org)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+// returns a key-level endorsement policy that requires a peer of every one of the organizations
+func CreateEndorsementPolicy(orgs []string) ([]byte, error) {
+	policy, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = policy.AddOrgs(statebased.RoleTypePeer, orgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy.Policy()
+}
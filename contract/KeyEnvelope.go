@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This structure is modeling the content-encryption key of a repo for one key epoch,
+// wrapped with one of the registered public keys of a reader. The key itself is generated
+// and wrapped by clients, it never reaches the ledger in plaintext.
+type KeyEnvelope struct {
+	RepoAuthor     string    `json:"repoAuthor"`
+	RepoName       string    `json:"repoName"`
+	Epoch          int       `json:"epoch"`
+	User           string    `json:"user"`
+	KeyFingerprint string    `json:"keyFingerprint"` // fingerprint of the public key the content key is wrapped with
+	WrappedKey     string    `json:"wrappedKey"`     // base64 encoded
+	WrappedBy      string    `json:"wrappedBy"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// This function takes a json string that represents the marshalling of a list of KeyEnvelope
+// and returns the envelopes for the repo and epoch, wrapped by the mentioned user.
+func UnmarshalKeyEnvelopes(objectString string, repo *Repository, epoch int, wrappedBy string, timestamp time.Time) ([]KeyEnvelope, error) {
+	var envelopes []KeyEnvelope
+
+	err := json.Unmarshal([]byte(objectString), &envelopes)
+	if err != nil {
+		return envelopes, errors.New("Could not unmarshal key envelopes")
+	}
+
+	for i := range envelopes {
+		if envelopes[i].User == "" || envelopes[i].KeyFingerprint == "" || envelopes[i].WrappedKey == "" {
+			return envelopes, errors.New("Key envelopes must name a user, a key fingerprint and a wrapped key")
+		}
+		envelopes[i].RepoAuthor = repo.Author
+		envelopes[i].RepoName = repo.Name
+		envelopes[i].Epoch = epoch
+		envelopes[i].WrappedBy = wrappedBy
+		envelopes[i].Timestamp = timestamp
+	}
+
+	return envelopes, nil
+}
+
+// checks that the envelope is wrapped with one of the active keys of its user
+func (envelope *KeyEnvelope) CheckWrappingKey(keys []UserKey) error {
+	for _, key := range keys {
+		if key.Fingerprint == envelope.KeyFingerprint {
+			return nil
+		}
+	}
+	return errors.New("Key envelope of user " + envelope.User + " is not wrapped with one of their active keys")
+}
+
+// returns the readers of the repo that have no envelope among the provided ones, sorted
+func (repo *Repository) GetReadersWithoutEnvelope(envelopes []KeyEnvelope) []string {
+	wrapped := make(map[string]bool)
+	for _, envelope := range envelopes {
+		wrapped[envelope.User] = true
+	}
+
+	missing := make([]string, 0)
+	for _, reader := range repo.GetUsersWithCapability(CapabilityRead) {
+		if !wrapped[reader] {
+			missing = append(missing, reader)
+		}
+	}
+	sort.Strings(missing)
+
+	return missing
+}
+
+// moves the repo to a new key epoch if one of the provided readers can no longer read it,
+// so that content pushed afterwards is encrypted with a key the revoked readers never held.
+// Returns whether the epoch changed.
+func (repo *Repository) RotateKeyEpochOnRevocation(readersBefore []string) bool {
+	if !repo.IsEncrypted() {
+		return false
+	}
+
+	for _, reader := range readersBefore {
+		if !repo.CanRead(reader) {
+			repo.KeyEpoch++
+			return true
+		}
+	}
+	return false
+}
+
+// moves an encrypted repo to a new key epoch unconditionally, when the keys of a reader may be compromised.
+// Returns whether the epoch changed.
+func (repo *Repository) RotateKeyEpoch() bool {
+	if !repo.IsEncrypted() {
+		return false
+	}
+
+	repo.KeyEpoch++
+	return true
+}
+
+// returns the users holding one of the provided envelopes who can no longer read the repo, sorted.
+// Their access may have expired or been revoked without a transaction rotating the key epoch.
+func (repo *Repository) GetEnvelopeHoldersWithoutAccess(envelopes []KeyEnvelope) []string {
+	revoked := make([]string, 0)
+	for _, envelope := range envelopes {
+		if !repo.CanRead(envelope.User) {
+			revoked = append(revoked, envelope.User)
+		}
+	}
+	sort.Strings(revoked)
+
+	return revoked
+}
+
+// checks if the content of the repo is encrypted
+func (repo *Repository) IsEncrypted() bool {
+	return repo.KeyEpoch > 0
+}
+
+// checks that new commits were encrypted under the current key epoch of the repo
+func (repo *Repository) CheckCommitsKeyEpoch(commits []Commit) error {
+	if !repo.IsEncrypted() {
+		return nil
+	}
+
+	for _, commit := range commits {
+		if repo.CommitExists(commit.Hash) {
+			continue
+		}
+		if commit.KeyEpoch != repo.KeyEpoch {
+			return errors.New("Commit " + commit.Hash + " is not encrypted under the current key epoch " + strconv.Itoa(repo.KeyEpoch) + " of the repo")
+		}
+	}
+	return nil
+}
+
+// checks that commits can be pushed under the current key epoch of the repo, given the envelopes of that epoch.
+// The key of the epoch must have been distributed and no holder of it may have lost read access since.
+func (repo *Repository) CheckPushKeyEpoch(envelopes []KeyEnvelope, commits []Commit) error {
+	if !repo.IsEncrypted() {
+		return nil
+	}
+
+	if len(envelopes) == 0 {
+		return errors.New("Key epoch " + strconv.Itoa(repo.KeyEpoch) + " of repo " + repo.Name + " has not been distributed yet")
+	}
+
+	// readers whose access expired still hold the current key
+	if revoked := repo.GetEnvelopeHoldersWithoutAccess(envelopes); len(revoked) > 0 {
+		return errors.New("Users " + strings.Join(revoked, ", ") + " can no longer read repo " + repo.Name + ", the content key of epoch " +
+			strconv.Itoa(repo.KeyEpoch+1) + " must be distributed before the next push")
+	}
+
+	return repo.CheckCommitsKeyEpoch(commits)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// returns an encrypted repo of alice at key epoch 2, read by bob until expiresAt
+func testEncryptedRepo(expiresAt time.Time) Repository {
+	repo := testRepo(testBranch("main", testCommit("a", 0)))
+	repo.AddCommitHash(testCommit("a", 0))
+	repo.KeyEpoch = 2
+	repo.UpdateAccess("bob", ReadAccess, "alice", testEpoch, expiresAt)
+	return repo
+}
+
+func TestRepositoryCheckPushKeyEpoch(t *testing.T) {
+	envelopes := []KeyEnvelope{{Epoch: 2, User: "alice"}, {Epoch: 2, User: "bob"}}
+	current := testCommit("b", 1, "a")
+	current.KeyEpoch = 2
+	stale := testCommit("b", 1, "a")
+	stale.KeyEpoch = 1
+
+	tests := []struct {
+		name      string
+		envelopes []KeyEnvelope
+		commits   []Commit
+		at        time.Time
+		allowed   bool
+	}{
+		{"commit under the current epoch", envelopes, []Commit{current}, testEpoch, true},
+		{"commit under a previous epoch", envelopes, []Commit{stale}, testEpoch, false},
+		{"commit already in the repo", envelopes, []Commit{testCommit("a", 0)}, testEpoch, true},
+		{"epoch not distributed", nil, []Commit{current}, testEpoch, false},
+		{"holder whose access expired", envelopes, []Commit{current}, testEpoch.Add(2 * time.Hour), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := testEncryptedRepo(testEpoch.Add(time.Hour))
+			repo.CurrentTime = test.at
+
+			err := repo.CheckPushKeyEpoch(test.envelopes, test.commits)
+			if allowed := err == nil; allowed != test.allowed {
+				t.Fatalf("CheckPushKeyEpoch() = %v, want allowed %v", err, test.allowed)
+			}
+		})
+	}
+
+	// repos that are not encrypted accept any commit
+	repo := testRepo()
+	if err := repo.CheckPushKeyEpoch(nil, []Commit{stale}); err != nil {
+		t.Fatalf("CheckPushKeyEpoch() = %v for a repo that is not encrypted", err)
+	}
+}
+
+func TestRepositoryRotateKeyEpochOnRevocation(t *testing.T) {
+	repo := testEncryptedRepo(time.Time{})
+	readers := repo.GetUsersWithCapability(CapabilityRead)
+
+	repo.UpdateAccess("carol", ReadAccess, "alice", testEpoch, time.Time{})
+	if repo.RotateKeyEpochOnRevocation(readers) || repo.KeyEpoch != 2 {
+		t.Fatalf("key epoch rotated when a reader was added")
+	}
+
+	repo.UpdateAccess("bob", NoAccess, "alice", testEpoch.Add(time.Minute), time.Time{})
+	if !repo.RotateKeyEpochOnRevocation(readers) || repo.KeyEpoch != 3 {
+		t.Fatalf("key epoch = %d after a reader was revoked, want 3", repo.KeyEpoch)
+	}
+}
+
+func TestUnmarshalKeyEnvelopes(t *testing.T) {
+	repo := testEncryptedRepo(time.Time{})
+
+	envelopes, err := UnmarshalKeyEnvelopes(`[{"user": "bob", "keyFingerprint": "f", "wrappedKey": "k", "epoch": 9, "repoName": "other"}]`, &repo, 3, "alice", testEpoch)
+	if err != nil {
+		t.Fatalf("UnmarshalKeyEnvelopes() = %v", err)
+	}
+	if envelopes[0].Epoch != 3 || envelopes[0].RepoName != "repo" || envelopes[0].WrappedBy != "alice" {
+		t.Fatalf("envelope = %+v, want it bound to epoch 3 of the repo and wrapped by alice", envelopes[0])
+	}
+
+	if _, err := UnmarshalKeyEnvelopes(`[{"user": "bob", "keyFingerprint": "f"}]`, &repo, 3, "alice", testEpoch); err == nil {
+		t.Fatalf("envelope without a wrapped key accepted")
+	}
+}
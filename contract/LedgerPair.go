@@ -15,9 +15,11 @@ import (
 
 // This is the resulting key-value pair generated to store some the data
 // in the Hyperledger after the maping from complex data structurs is done.
+// Pairs with a collection are stored in that private data collection instead of the world state.
 type LedgerPair struct {
-	key   string
-	value []byte
+	key        string
+	value      []byte
+	collection string
 }
 
 func generateUserDBPair(stub shim.ChaincodeStubInterface, user User) (LedgerPair, error) {
@@ -29,13 +31,150 @@ func generateUserDBPair(stub shim.ChaincodeStubInterface, user User) (LedgerPair
 	pair.key = userHash
 
 	value := map[string]interface{}{"docName": "user", "userID": userHash, "name": user.PublicInfo.Name,
-		"email": user.PublicInfo.Email, "publicKey": user.PublicInfo.PublicKey}
+		"emailHash": user.PublicInfo.EmailHash, "publicKey": user.PublicInfo.PublicKey}
 
 	pair.value, _ = json.Marshal(value)
 
 	return pair, nil
 }
 
+func getUserPIIKey(stub shim.ChaincodeStubInterface, name string) (string, error) {
+	return stub.CreateCompositeKey("index-UserPII", []string{name})
+}
+
+func generateUserPIIDBPair(stub shim.ChaincodeStubInterface, pii UserPII) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getUserPIIKey(stub, pii.Name)
+	pair.collection = UserPIICollection
+
+	value := map[string]interface{}{"docName": "userPII", "name": pii.Name, "email": pii.Email, "salt": pii.Salt}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func getCommitPIIKey(stub shim.ChaincodeStubInterface, author string, repoName string, commitHash string) (string, error) {
+	return stub.CreateCompositeKey("index-CommitPII", []string{getRepoKey(author, repoName), commitHash})
+}
+
+// the git author email of a commit is kept with the personal data of users rather than in the commit document,
+// so that it can be purged on its own
+func generateCommitPIIDBPair(stub shim.ChaincodeStubInterface, repo Repository, commit Commit) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getCommitPIIKey(stub, repo.Author, repo.Name, commit.Hash)
+	pair.collection = UserPIICollection
+
+	value := map[string]interface{}{"docName": "commitPII", "repoID": getRepoKey(repo.Author, repo.Name), "repoAuthor": repo.Author,
+		"repoName": repo.Name, "hash": commit.Hash, "email": commit.AuthorEmail}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func generateUserKeyDBPair(stub shim.ChaincodeStubInterface, key UserKey) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	indexName := "index-UserKey"
+	userKeyIndexKey, _ := stub.CreateCompositeKey(indexName, []string{key.Name, key.Fingerprint})
+
+	pair.key = userKeyIndexKey
+
+	value := map[string]interface{}{"docName": "userKey", "name": key.Name, "label": key.Label, "publicKey": key.PublicKey, "fingerprint": key.Fingerprint,
+		"validFrom": key.ValidFrom, "validUntil": key.ValidUntil, "revocationReason": key.RevocationReason}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func getOrganizationKey(stub shim.ChaincodeStubInterface, name string) (string, error) {
+	return stub.CreateCompositeKey("index-Organization", []string{name})
+}
+
+func generateOrganizationDBPair(stub shim.ChaincodeStubInterface, organization Organization) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getOrganizationKey(stub, organization.Name)
+
+	owners, _ := json.Marshal(organization.Owners)
+	value := map[string]interface{}{"docName": "organization", "name": organization.Name, "owners": string(owners)}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func getTeamKey(stub shim.ChaincodeStubInterface, organization string, name string) (string, error) {
+	return stub.CreateCompositeKey("index-Team", []string{organization, name})
+}
+
+func generateTeamDBPair(stub shim.ChaincodeStubInterface, team Team) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getTeamKey(stub, team.Organization, team.Name)
+
+	members, _ := json.Marshal(team.Members)
+	value := map[string]interface{}{"docName": "team", "organization": team.Organization, "name": team.Name, "members": string(members)}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func getIdentityKey(stub shim.ChaincodeStubInterface, identity UserIdentity) (string, error) {
+	return stub.CreateCompositeKey("index-Identity", []string{identity.MSPID, identity.ID})
+}
+
+func generateIdentityDBPair(stub shim.ChaincodeStubInterface, identity UserIdentity, userName string, keyFingerprint string) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getIdentityKey(stub, identity)
+
+	value := map[string]interface{}{"docName": "identity", "mspID": identity.MSPID, "identityID": identity.ID, "name": userName, "keyFingerprint": keyFingerprint}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+// sessions index the identities bound to a user by the key they logged in with, so that they can be ended with the key
+func getUserSessionKey(stub shim.ChaincodeStubInterface, userName string, keyFingerprint string, identity UserIdentity) (string, error) {
+	return stub.CreateCompositeKey("index-UserSession", []string{userName, keyFingerprint, identity.MSPID, identity.ID})
+}
+
+func generateUserSessionDBPair(stub shim.ChaincodeStubInterface, userName string, keyFingerprint string, identity UserIdentity) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getUserSessionKey(stub, userName, keyFingerprint, identity)
+
+	value := map[string]interface{}{"docName": "userSession", "name": userName, "keyFingerprint": keyFingerprint, "mspID": identity.MSPID, "identityID": identity.ID}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func getLoginChallengeKey(stub shim.ChaincodeStubInterface, name string, challengeID string) (string, error) {
+	return stub.CreateCompositeKey("index-LoginChallenge", []string{name, challengeID})
+}
+
+func generateLoginChallengeDBPair(stub shim.ChaincodeStubInterface, challenge LoginChallenge) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getLoginChallengeKey(stub, challenge.Name, challenge.ChallengeID)
+
+	identity, _ := json.Marshal(challenge.Identity)
+	value := map[string]interface{}{"docName": "loginChallenge", "challengeID": challenge.ChallengeID, "name": challenge.Name, "nonce": challenge.Nonce, "identity": string(identity), "timestamp": challenge.Timestamp, "used": strconv.FormatBool(challenge.Used)}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
 func generateRepoDBPair(stub shim.ChaincodeStubInterface, repo Repository) ([]LedgerPair, error) {
 
 	repoHash := getRepoKey(repo.Author, repo.Name)
@@ -45,30 +184,112 @@ func generateRepoDBPair(stub shim.ChaincodeStubInterface, repo Repository) ([]Le
 
 	pair.key = repoHash
 	accessLogs, _ := json.Marshal(repo.AccessLogs)
+	endorsingOrgs, _ := json.Marshal(repo.EndorsingOrgs)
 
 	value := map[string]interface{}{"docName": "repo", "repoID": repoHash, "name": repo.Name,
-		"author": repo.Author, "directoryCID": repo.DirectoryCID, "accessLogs": string(accessLogs)}
+		"author": repo.Author, "directoryCID": repo.DirectoryCID, "accessLogs": string(accessLogs),
+		"requireSignedCommits": strconv.FormatBool(repo.RequireSignedCommits), "visibility": string(repo.Visibility),
+		"ownershipRecoveryQuorum": strconv.Itoa(repo.OwnershipRecoveryQuorum), "endorsingOrgs": string(endorsingOrgs), "privateCollection": repo.PrivateCollection,
+		"keyEpoch": strconv.Itoa(repo.KeyEpoch), "requiredApprovals": strconv.Itoa(repo.RequiredApprovals)}
 
 	pair.value, _ = json.Marshal(value)
 
+	// the document of a private repo is only stored in its collection, the public state
+	// only points to the collection and commits to the document's content
+	if repo.PrivateCollection != "" {
+		var privatePair LedgerPair
+
+		privatePair.key = repoHash
+		privatePair.collection = repo.PrivateCollection
+		privatePair.value = pair.value
+
+		documentHash := sha256.Sum256(privatePair.value)
+		publicValue := map[string]interface{}{"docName": "repo", "repoID": repoHash, "privateCollection": repo.PrivateCollection,
+			"documentHash": b64.StdEncoding.EncodeToString(documentHash[:])}
+		pair.value, _ = json.Marshal(publicValue)
+
+		list = append(list, pair, privatePair)
+		return list, nil
+	}
+
 	list = append(list, pair)
 
 	return list, nil
 }
 
-func generateRepoBranchDBPair(stub shim.ChaincodeStubInterface, author string, repoName string, branch Branch) (LedgerPair, error) {
+// returns the pairs of the documents of the repo: its commits, branches, protection rules, accesses and the repo itself,
+// in the order they are deleted
+func generateRepoDocumentsDBPair(stub shim.ChaincodeStubInterface, repo Repository) ([]LedgerPair, error) {
+
+	list := make([]LedgerPair, 0)
+
+	branchCommitPairs, _ := generateRepoBranchesCommitsDBPair(stub, repo)
+	list = append(list, branchCommitPairs...)
+
+	branchPairs, _ := generateRepoBranchesDBPair(stub, repo)
+	list = append(list, branchPairs...)
+
+	protectionPairs, _ := generateRepoBranchProtectionsDBPair(stub, repo)
+	list = append(list, protectionPairs...)
+
+	tagProtectionPairs, _ := generateRepoTagProtectionsDBPair(stub, repo)
+	list = append(list, tagProtectionPairs...)
+
+	accessPairs, _ := generateRepoUserAccessesDBPair(stub, repo)
+	list = append(list, accessPairs...)
+
+	repoPairs, _ := generateRepoDBPair(stub, repo)
+	list = append(list, repoPairs...)
+
+	return list, nil
+}
+
+func generateRepoRedirectDBPair(stub shim.ChaincodeStubInterface, author string, repoName string, newAuthor string, newRepoName string) (LedgerPair, error) {
 
 	repoHash := getRepoKey(author, repoName)
 
 	var pair LedgerPair
 
+	pair.key = repoHash
+
+	value := map[string]interface{}{"docName": "repoRedirect", "repoID": repoHash, "name": repoName, "author": author,
+		"redirectAuthor": newAuthor, "redirectName": newRepoName}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func getRepoTransferKey(stub shim.ChaincodeStubInterface, author string, repoName string) (string, error) {
+	return stub.CreateCompositeKey("index-RepoTransfer", []string{getRepoKey(author, repoName)})
+}
+
+func generateRepoTransferDBPair(stub shim.ChaincodeStubInterface, transfer RepoTransfer) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getRepoTransferKey(stub, transfer.RepoAuthor, transfer.RepoName)
+
+	value := map[string]interface{}{"docName": "repoTransfer", "repoID": getRepoKey(transfer.RepoAuthor, transfer.RepoName), "repoAuthor": transfer.RepoAuthor,
+		"repoName": transfer.RepoName, "newAuthor": transfer.NewAuthor, "requester": transfer.Requester, "timestamp": transfer.Timestamp}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func generateRepoBranchDBPair(stub shim.ChaincodeStubInterface, repo Repository, branch Branch) (LedgerPair, error) {
+
+	repoHash := getRepoKey(repo.Author, repo.Name)
+
+	var pair LedgerPair
+
 	indexName := "index-Branch"
 	branchIndexKey, _ := stub.CreateCompositeKey(indexName, []string{repoHash, branch.Name})
 
 	fmt.Println("branchIndexKey : " + branchIndexKey)
 	pair.key = branchIndexKey
+	pair.collection = repo.PrivateCollection
 
-	value := map[string]interface{}{"docName": "branch", "repoID": repoHash, "branchName": branch.Name}
+	value := map[string]interface{}{"docName": "branch", "repoID": repoHash, "branchName": branch.Name, "head": branch.Head}
 	pair.value, _ = json.Marshal(value)
 
 	return pair, nil
@@ -79,27 +300,112 @@ func generateRepoBranchesDBPair(stub shim.ChaincodeStubInterface, repo Repositor
 	list := make([]LedgerPair, 0)
 
 	for _, branch := range repo.Branches {
-		pair, _ := generateRepoBranchDBPair(stub, repo.Author, repo.Name, branch)
+		pair, _ := generateRepoBranchDBPair(stub, repo, branch)
 		list = append(list, pair)
 	}
 
 	return list, nil
 }
 
-func generateRepoBranchCommitDBPair(stub shim.ChaincodeStubInterface, author string, repoName string, branchName string, commit Commit) (LedgerPair, error) {
+func generateRepoBranchProtectionDBPair(stub shim.ChaincodeStubInterface, author string, repoName string, rule BranchProtectionRule) (LedgerPair, error) {
 
 	repoHash := getRepoKey(author, repoName)
 
 	var pair LedgerPair
 
+	indexName := "index-BranchProtection"
+	branchProtectionIndexKey, _ := stub.CreateCompositeKey(indexName, []string{repoHash, rule.Pattern})
+
+	pair.key = branchProtectionIndexKey
+
+	value := map[string]interface{}{"docName": "branchProtection", "repoID": repoHash, "pattern": rule.Pattern, "noDeletion": rule.NoDeletion, "noRename": rule.NoRename,
+		"ownerOnlyPush": rule.OwnerOnlyPush, "requireSignedCommits": rule.RequireSignedCommits, "requireLinearHistory": rule.RequireLinearHistory,
+		"endorsingOrgs": rule.EndorsingOrgs}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func generateRepoBranchProtectionsDBPair(stub shim.ChaincodeStubInterface, repo Repository) ([]LedgerPair, error) {
+
+	list := make([]LedgerPair, 0)
+
+	for _, rule := range repo.BranchProtections {
+		pair, _ := generateRepoBranchProtectionDBPair(stub, repo.Author, repo.Name, rule)
+		list = append(list, pair)
+	}
+
+	return list, nil
+}
+
+func generateRepoTagProtectionDBPair(stub shim.ChaincodeStubInterface, author string, repoName string, rule TagProtectionRule) (LedgerPair, error) {
+
+	repoHash := getRepoKey(author, repoName)
+
+	var pair LedgerPair
+
+	indexName := "index-TagProtection"
+	tagProtectionIndexKey, _ := stub.CreateCompositeKey(indexName, []string{repoHash, rule.Pattern})
+
+	pair.key = tagProtectionIndexKey
+
+	value := map[string]interface{}{"docName": "tagProtection", "repoID": repoHash, "pattern": rule.Pattern, "noMove": rule.NoMove,
+		"noDeletion": rule.NoDeletion, "ownerOnly": rule.OwnerOnly}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func generateRepoTagProtectionsDBPair(stub shim.ChaincodeStubInterface, repo Repository) ([]LedgerPair, error) {
+
+	list := make([]LedgerPair, 0)
+
+	for _, rule := range repo.TagProtections {
+		pair, _ := generateRepoTagProtectionDBPair(stub, repo.Author, repo.Name, rule)
+		list = append(list, pair)
+	}
+
+	return list, nil
+}
+
+func getTagKey(stub shim.ChaincodeStubInterface, author string, repoName string, tagName string) (string, error) {
+	return stub.CreateCompositeKey("index-Tag", []string{getRepoKey(author, repoName), tagName})
+}
+
+// tags of private repos are kept in their private data collection
+func generateTagDBPair(stub shim.ChaincodeStubInterface, repo Repository, tag Tag) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getTagKey(stub, tag.RepoAuthor, tag.RepoName, tag.Name)
+	pair.collection = repo.PrivateCollection
+
+	value := map[string]interface{}{"docName": "tag", "repoID": getRepoKey(tag.RepoAuthor, tag.RepoName), "name": tag.Name,
+		"repoAuthor": tag.RepoAuthor, "repoName": tag.RepoName, "commit": tag.Commit, "tagger": tag.Tagger, "message": tag.Message,
+		"signature": tag.Signature, "signatureFormat": tag.SignatureFormat, "verified": tag.Verified, "createdBy": tag.CreatedBy,
+		"timestamp": tag.Timestamp}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func generateRepoBranchCommitDBPair(stub shim.ChaincodeStubInterface, repo Repository, branchName string, commit Commit) (LedgerPair, error) {
+
+	repoHash := getRepoKey(repo.Author, repo.Name)
+
+	var pair LedgerPair
+
 	indexName := "index-BranchCommits"
 	branchCommitIndexKey, _ := stub.CreateCompositeKey(indexName, []string{repoHash, branchName, commit.Hash})
 
 	pair.key = branchCommitIndexKey
+	pair.collection = repo.PrivateCollection
 
 	parentHashes, _ := json.Marshal(commit.ParentHashes)
 	storageHashes, _ := json.Marshal(commit.StorageHashes)
-	value := map[string]interface{}{"docName": "commit", "repoID": repoHash, "branchName": branchName, "hash": commit.Hash, "message": commit.Message, "author": commit.Author, "authorEmail": commit.AuthorEmail, "timestamp": commit.Timestamp, "parentHashes": string(parentHashes), "storageHashes": string(storageHashes)}
+	value := map[string]interface{}{"docName": "commit", "repoID": repoHash, "branchName": branchName, "hash": commit.Hash, "message": commit.Message, "author": commit.Author, "authorID": commit.AuthorID, "timestamp": commit.Timestamp, "parentHashes": string(parentHashes), "storageHashes": string(storageHashes),
+		"signature": commit.Signature, "signatureFormat": commit.SignatureFormat, "verified": strconv.FormatBool(commit.Verified),
+		"keyEpoch": strconv.Itoa(commit.KeyEpoch)}
 	pair.value, _ = json.Marshal(value)
 
 	return pair, nil
@@ -110,7 +416,7 @@ func generateRepoBranchesCommitsDBPair(stub shim.ChaincodeStubInterface, repo Re
 	list := make([]LedgerPair, 0)
 	for _, branch := range repo.Branches {
 		for _, log := range branch.Commits {
-			pair, _ := generateRepoBranchCommitDBPair(stub, repo.Author, repo.Name, branch.Name, log)
+			pair, _ := generateRepoBranchCommitDBPair(stub, repo, branch.Name, log)
 			list = append(list, pair)
 		}
 	}
@@ -118,24 +424,24 @@ func generateRepoBranchesCommitsDBPair(stub shim.ChaincodeStubInterface, repo Re
 	return list, nil
 }
 
-func generateRepoBranchesCommitsDBPairUsingBranch(stub shim.ChaincodeStubInterface, author string, repoName string, repoBranch Branch) ([]LedgerPair, error) {
+func generateRepoBranchesCommitsDBPairUsingBranch(stub shim.ChaincodeStubInterface, repo Repository, repoBranch Branch) ([]LedgerPair, error) {
 
 	list := make([]LedgerPair, 0)
 
 	for _, log := range repoBranch.Commits {
-		pair, _ := generateRepoBranchCommitDBPair(stub, author, repoName, repoBranch.Name, log)
+		pair, _ := generateRepoBranchCommitDBPair(stub, repo, repoBranch.Name, log)
 		list = append(list, pair)
 	}
 
 	return list, nil
 }
 
-func generateRepoBranchesCommitsDBPairUsingPush(stub shim.ChaincodeStubInterface, author string, repoName string, push Push) ([]LedgerPair, error) {
+func generateRepoBranchesCommitsDBPairUsingPush(stub shim.ChaincodeStubInterface, repo Repository, push Push) ([]LedgerPair, error) {
 
 	list := make([]LedgerPair, 0)
 
 	for _, log := range push.Commits {
-		pair, _ := generateRepoBranchCommitDBPair(stub, author, repoName, push.BranchName, log)
+		pair, _ := generateRepoBranchCommitDBPair(stub, repo, push.BranchName, log)
 		list = append(list, pair)
 	}
 
@@ -170,19 +476,21 @@ func getRepoKey(author string, repoName string) string {
 	return sEnc
 }
 
-func generateRepoUserAccessDBPair(stub shim.ChaincodeStubInterface, author string, repoName string, authorized string, userAccess string, authorizer string, timestamp time.Time) (LedgerPair, error) {
+func generateRepoUserAccessDBPair(stub shim.ChaincodeStubInterface, author string, repoName string, accessLog AccessLog) (LedgerPair, error) {
 
 	repoHash := getRepoKey(author, repoName)
 
 	var pair LedgerPair
 
 	indexName := "index-RepoUserAccess"
-	repoUserAccessIndexKey, _ := stub.CreateCompositeKey(indexName, []string{repoHash, authorized, timestamp.Format(time.RFC3339)})
+	repoUserAccessIndexKey, _ := stub.CreateCompositeKey(indexName, []string{repoHash, accessLog.Authorized, accessLog.Timestamp.Format(time.RFC3339)})
 
 	fmt.Println(indexName + " : \n" + repoUserAccessIndexKey)
 	pair.key = repoUserAccessIndexKey
 
-	value := map[string]interface{}{"docName": "userAccess", "repoID": repoHash, "authorized": authorized, "userAccess": userAccess, "authorizer": authorizer, "timestamp": timestamp}
+	value := map[string]interface{}{"docName": "userAccess", "repoID": repoHash, "authorized": accessLog.Authorized, "userAccess": strconv.Itoa(int(accessLog.UserAccess)),
+		"authorizer": accessLog.Authorizer, "timestamp": accessLog.Timestamp, "expiresAt": accessLog.ExpiresAt, "visibility": string(accessLog.Visibility),
+		"requestID": accessLog.RequestID, "requestStatus": string(accessLog.RequestStatus)}
 	pair.value, _ = json.Marshal(value)
 
 	return pair, nil
@@ -192,10 +500,136 @@ func generateRepoUserAccessesDBPair(stub shim.ChaincodeStubInterface, repo Repos
 
 	list := make([]LedgerPair, 0)
 
-	for _, userAccess := range repo.AccessLogs {
-		pair, _ := generateRepoUserAccessDBPair(stub, repo.Author, repo.Name, userAccess.Authorized, strconv.Itoa(int(userAccess.UserAccess)), userAccess.Authorizer, userAccess.Timestamp)
+	for _, accessLog := range repo.AccessLogs {
+		pair, _ := generateRepoUserAccessDBPair(stub, repo.Author, repo.Name, accessLog)
 		list = append(list, pair)
 	}
 
 	return list, nil
 }
+
+func getRepoKeyEnvelopeKey(stub shim.ChaincodeStubInterface, author string, repoName string, epoch int, user string) (string, error) {
+	return stub.CreateCompositeKey("index-RepoKeyEnvelope", []string{getRepoKey(author, repoName), strconv.Itoa(epoch), user})
+}
+
+func generateRepoKeyEnvelopeDBPair(stub shim.ChaincodeStubInterface, envelope KeyEnvelope) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getRepoKeyEnvelopeKey(stub, envelope.RepoAuthor, envelope.RepoName, envelope.Epoch, envelope.User)
+
+	value := map[string]interface{}{"docName": "repoKeyEnvelope", "repoID": getRepoKey(envelope.RepoAuthor, envelope.RepoName),
+		"repoAuthor": envelope.RepoAuthor, "repoName": envelope.RepoName, "epoch": envelope.Epoch, "user": envelope.User,
+		"keyFingerprint": envelope.KeyFingerprint, "wrappedKey": envelope.WrappedKey, "wrappedBy": envelope.WrappedBy,
+		"timestamp": envelope.Timestamp}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func getReflogEntryKey(stub shim.ChaincodeStubInterface, author string, repoName string, branchName string, entryID string) (string, error) {
+	return stub.CreateCompositeKey("index-Reflog", []string{getRepoKey(author, repoName), branchName, entryID})
+}
+
+// reflog entries of private repos hold commits of the repo, so they are kept in its private data collection
+func generateReflogEntryDBPair(stub shim.ChaincodeStubInterface, repo Repository, entry ReflogEntry) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getReflogEntryKey(stub, entry.RepoAuthor, entry.RepoName, entry.BranchName, entry.ID)
+	pair.collection = repo.PrivateCollection
+
+	// the emails of commit authors are only kept in the collection of personal data
+	droppedCommits := make([]Commit, 0, len(entry.DroppedCommits))
+	for _, commit := range entry.DroppedCommits {
+		commit.AuthorEmail = ""
+		droppedCommits = append(droppedCommits, commit)
+	}
+
+	value := map[string]interface{}{"docName": "reflog", "repoID": getRepoKey(entry.RepoAuthor, entry.RepoName), "id": entry.ID,
+		"repoAuthor": entry.RepoAuthor, "repoName": entry.RepoName, "branchName": entry.BranchName, "action": entry.Action,
+		"oldHead": entry.OldHead, "newHead": entry.NewHead, "oldBranchName": entry.OldBranchName, "user": entry.User,
+		"droppedCommits": droppedCommits, "timestamp": entry.Timestamp}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func getPullRequestKey(stub shim.ChaincodeStubInterface, author string, repoName string, pullRequestID string) (string, error) {
+	return stub.CreateCompositeKey("index-PullRequest", []string{getRepoKey(author, repoName), pullRequestID})
+}
+
+// pull requests of private repos are kept in their private data collection
+func generatePullRequestDBPair(stub shim.ChaincodeStubInterface, repo Repository, pullRequest PullRequest) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getPullRequestKey(stub, pullRequest.RepoAuthor, pullRequest.RepoName, pullRequest.ID)
+	pair.collection = repo.PrivateCollection
+
+	value := map[string]interface{}{"docName": "pullRequest", "repoID": getRepoKey(pullRequest.RepoAuthor, pullRequest.RepoName), "id": pullRequest.ID,
+		"repoAuthor": pullRequest.RepoAuthor, "repoName": pullRequest.RepoName, "targetBranch": pullRequest.TargetBranch,
+		"sourceRepoAuthor": pullRequest.SourceRepoAuthor, "sourceRepoName": pullRequest.SourceRepoName, "sourceBranch": pullRequest.SourceBranch,
+		"author": pullRequest.Author, "title": pullRequest.Title, "description": pullRequest.Description, "state": pullRequest.State,
+		"heads": pullRequest.Heads, "timestamp": pullRequest.Timestamp, "resolvedBy": pullRequest.ResolvedBy, "resolvedAt": pullRequest.ResolvedAt}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func getReviewKey(stub shim.ChaincodeStubInterface, author string, repoName string, pullRequestID string, reviewID string) (string, error) {
+	return stub.CreateCompositeKey("index-Review", []string{getRepoKey(author, repoName), pullRequestID, reviewID})
+}
+
+// reviews of private repos are kept in their private data collection
+func generateReviewDBPair(stub shim.ChaincodeStubInterface, repo Repository, review Review) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getReviewKey(stub, review.RepoAuthor, review.RepoName, review.PullRequestID, review.ID)
+	pair.collection = repo.PrivateCollection
+
+	value := map[string]interface{}{"docName": "review", "repoID": getRepoKey(review.RepoAuthor, review.RepoName), "id": review.ID,
+		"repoAuthor": review.RepoAuthor, "repoName": review.RepoName, "pullRequestID": review.PullRequestID, "targetBranch": review.TargetBranch,
+		"commit": review.Commit, "reviewer": review.Reviewer, "kind": review.Kind, "body": review.Body, "signature": review.Signature,
+		"signatureFormat": review.SignatureFormat, "verified": review.Verified, "timestamp": review.Timestamp}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func getOwnershipRecoveryKey(stub shim.ChaincodeStubInterface, author string, repoName string, recoveryID string) (string, error) {
+	return stub.CreateCompositeKey("index-OwnershipRecovery", []string{getRepoKey(author, repoName), recoveryID})
+}
+
+func generateOwnershipRecoveryDBPair(stub shim.ChaincodeStubInterface, recovery OwnershipRecovery) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getOwnershipRecoveryKey(stub, recovery.RepoAuthor, recovery.RepoName, recovery.ID)
+
+	value := map[string]interface{}{"docName": "ownershipRecovery", "repoID": getRepoKey(recovery.RepoAuthor, recovery.RepoName), "id": recovery.ID,
+		"repoAuthor": recovery.RepoAuthor, "repoName": recovery.RepoName, "newOwner": recovery.NewOwner, "approvals": recovery.Approvals,
+		"status": recovery.Status, "timestamp": recovery.Timestamp}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
+
+func getAccessRequestKey(stub shim.ChaincodeStubInterface, author string, repoName string, requestID string) (string, error) {
+	return stub.CreateCompositeKey("index-AccessRequest", []string{getRepoKey(author, repoName), requestID})
+}
+
+func generateAccessRequestDBPair(stub shim.ChaincodeStubInterface, request AccessRequest) (LedgerPair, error) {
+
+	var pair LedgerPair
+
+	pair.key, _ = getAccessRequestKey(stub, request.RepoAuthor, request.RepoName, request.ID)
+
+	value := map[string]interface{}{"docName": "accessRequest", "repoID": getRepoKey(request.RepoAuthor, request.RepoName), "id": request.ID,
+		"repoAuthor": request.RepoAuthor, "repoName": request.RepoName, "kind": request.Kind, "requester": request.Requester, "user": request.User,
+		"userAccess": request.UserAccess, "status": request.Status, "timestamp": request.Timestamp, "resolvedBy": request.ResolvedBy, "resolvedAt": request.ResolvedAt}
+	pair.value, _ = json.Marshal(value)
+
+	return pair, nil
+}
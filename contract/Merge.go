@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"slices"
+)
+
+// The ways a branch can be merged into another
+type MergeMode string
+
+const (
+	// the target branch moves to the head of the source branch, which must descend from it
+	MergeFastForward MergeMode = "fast-forward"
+	// the target branch moves to a merge commit whose parents are both heads
+	MergeCommit MergeMode = "merge-commit"
+)
+
+// checks if a commit is an ancestor of another commit, or the commit itself, in the commit graph of the repo
+func (repo *Repository) IsAncestor(ancestor string, descendant string) bool {
+	return GetAncestors(descendant, repo.GetCommits())[ancestor]
+}
+
+// Merges the source branch into the target branch. A merge commit is only used by the merge-commit mode
+// and must be validated as a pushed commit would be.
+// returns the commits the target branch gained
+func (repo *Repository) MergeBranch(sourceName string, targetName string, mode MergeMode, mergeCommit Commit) ([]Commit, error) {
+	if !repo.BranchExists(sourceName) || !repo.BranchExists(targetName) {
+		return nil, errors.New("Both branches must exist in the repo!")
+	}
+
+	if sourceName == targetName {
+		return nil, errors.New("A branch cannot be merged into itself!")
+	}
+
+	source := repo.Branches[sourceName]
+	target := repo.Branches[targetName]
+	if _, exist := source.GetHead(); !exist {
+		return nil, errors.New("Branch " + sourceName + " has no commits to merge!")
+	}
+
+	if target.Head != "" && repo.IsAncestor(source.Head, target.Head) {
+		return nil, errors.New("Branch " + sourceName + " is already merged into " + targetName + "!")
+	}
+
+	knownCommits := repo.GetCommits()
+	newHead := source.Head
+
+	switch mode {
+	case MergeFastForward:
+		if target.Head != "" && !repo.IsAncestor(target.Head, source.Head) {
+			return nil, errors.New("Branch " + targetName + " cannot be fast-forwarded: its head is not an ancestor of the head of " + sourceName + "!")
+		}
+	case MergeCommit:
+		if target.Head == "" {
+			return nil, errors.New("Branch " + targetName + " has no commits, it can only be fast-forwarded!")
+		}
+		if repo.IsAncestor(target.Head, source.Head) {
+			return nil, errors.New("Branch " + targetName + " can be fast-forwarded, no merge commit is needed!")
+		}
+		if len(mergeCommit.ParentHashes) != 2 || !slices.Contains(mergeCommit.ParentHashes, target.Head) || !slices.Contains(mergeCommit.ParentHashes, source.Head) {
+			return nil, errors.New("Parents of merge commit " + mergeCommit.Hash + " must be the heads of " + targetName + " and " + sourceName + "!")
+		}
+		if _, exist := knownCommits[mergeCommit.Hash]; exist {
+			return nil, errors.New("Commit " + mergeCommit.Hash + " already exists in the repo!")
+		}
+		if _, err := target.ValidCommit(mergeCommit, knownCommits, false); err != nil {
+			return nil, err
+		}
+		knownCommits[mergeCommit.Hash] = mergeCommit
+		newHead = mergeCommit.Hash
+	default:
+		return nil, errors.New("Unknown merge mode " + string(mode) + "!")
+	}
+
+	dropped, err := repo.SetBranchHead(targetName, newHead, knownCommits)
+	if err != nil {
+		return nil, err
+	}
+	if len(dropped) > 0 {
+		return nil, errors.New("Merge would drop commits from branch " + targetName + "!")
+	}
+
+	added := make([]Commit, 0)
+	for hash, commit := range repo.Branches[targetName].Commits {
+		if !target.CommitExists(hash) {
+			added = append(added, commit)
+		}
+	}
+
+	return added, nil
+}
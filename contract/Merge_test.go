@@ -0,0 +1,75 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRepositoryMergeBranch(t *testing.T) {
+	a := testCommit("a", 0)
+	b := testCommit("b", 1, "a")
+	c := testCommit("c", 2, "b")
+	d := testCommit("d", 3, "c")
+	x := testCommit("x", 2, "b")
+
+	tests := []struct {
+		name        string
+		source      string
+		target      string
+		mode        MergeMode
+		mergeCommit Commit
+		head        string   // head of the target after the merge, empty if the merge fails
+		added       []string // hashes of the commits the target gained
+	}{
+		{"unknown branch", "missing", "main", MergeFastForward, Commit{}, "", nil},
+		{"into itself", "main", "main", MergeFastForward, Commit{}, "", nil},
+		{"empty source", "empty", "main", MergeFastForward, Commit{}, "", nil},
+		{"already merged", "old", "main", MergeFastForward, Commit{}, "", nil},
+		{"unknown mode", "feature", "main", MergeMode("rebase"), Commit{}, "", nil},
+		{"fast-forward", "feature", "main", MergeFastForward, Commit{}, "d", []string{"c", "d"}},
+		{"fast-forward an empty target", "feature", "empty", MergeFastForward, Commit{}, "d", []string{"a", "b", "c", "d"}},
+		{"fast-forward diverged branches", "topic", "main", MergeFastForward, Commit{}, "", nil},
+		{"merge commit when fast-forward is possible", "feature", "main", MergeCommit, testCommit("m", 5, "b", "d"), "", nil},
+		{"merge commit into an empty target", "feature", "empty", MergeCommit, testCommit("m", 5, "d"), "", nil},
+		{"merge commit", "topic", "main", MergeCommit, testCommit("m", 5, "c", "x"), "m", []string{"m", "x"}},
+		{"merge commit with parents swapped", "topic", "main", MergeCommit, testCommit("m", 5, "x", "c"), "m", []string{"m", "x"}},
+		{"merge commit missing a parent", "topic", "main", MergeCommit, testCommit("m", 5, "c"), "", nil},
+		{"merge commit with a wrong parent", "topic", "main", MergeCommit, testCommit("m", 5, "c", "b"), "", nil},
+		{"merge commit with an existing hash", "topic", "main", MergeCommit, testCommit("d", 5, "c", "x"), "", nil},
+		{"merge commit not newer than its parents", "topic", "main", MergeCommit, testCommit("m", 2, "c", "x"), "", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mainBranch := testBranch("main", a, b, c)
+			if test.source == "feature" {
+				mainBranch = testBranch("main", a, b)
+			}
+			repo := testRepo(mainBranch, testBranch("feature", a, b, c, d), testBranch("topic", a, b, x),
+				testBranch("old", a), testBranch("empty"))
+
+			added, err := repo.MergeBranch(test.source, test.target, test.mode, test.mergeCommit)
+			if test.head == "" {
+				if err == nil {
+					t.Fatalf("MergeBranch() accepted the merge, head is now %s", repo.Branches[test.target].Head)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MergeBranch() = %v, want the head at %s", err, test.head)
+			}
+			if head := repo.Branches[test.target].Head; head != test.head {
+				t.Fatalf("head = %s, want %s", head, test.head)
+			}
+
+			addedHashes := make([]string, 0)
+			for _, commit := range added {
+				addedHashes = append(addedHashes, commit.Hash)
+			}
+			slices.Sort(addedHashes)
+			if !slices.Equal(addedHashes, test.added) {
+				t.Fatalf("added = %v, want %v", addedHashes, test.added)
+			}
+		})
+	}
+}
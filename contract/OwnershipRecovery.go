@@ -0,0 +1,68 @@
+package main
+
+import (
+	"time"
+)
+
+// This structure is modeling a request of maintainers and admins to restore ownership of a repo
+// to one of them, for instance after its only owner lost their key.
+// Ownership is restored once a quorum of members has approved; owners can cancel it meanwhile.
+type OwnershipRecovery struct {
+	ID         string              `json:"id"`
+	RepoAuthor string              `json:"repoAuthor"`
+	RepoName   string              `json:"repoName"`
+	NewOwner   string              `json:"newOwner"`
+	Approvals  map[string]bool     `json:"approvals"`
+	Status     AccessRequestStatus `json:"status"`
+	Timestamp  time.Time           `json:"timestamp"`
+}
+
+// Ownership cannot be restored before this delay has passed since the proposal,
+// leaving the current owners time to cancel it
+const OwnershipRecoveryDelay = 72 * time.Hour
+
+// helper function that creates a new ownership recovery approved by its proposer
+func CreateNewOwnershipRecovery(id string, repoAuthor string, repoName string, newOwner string, proposer string, timestamp time.Time) (OwnershipRecovery, error) {
+	var recovery OwnershipRecovery
+
+	recovery.ID = id
+	recovery.RepoAuthor = repoAuthor
+	recovery.RepoName = repoName
+	recovery.NewOwner = newOwner
+	recovery.Approvals = make(map[string]bool)
+	recovery.Approvals[proposer] = true
+	recovery.Status = AccessRequestPending
+	recovery.Timestamp = timestamp
+
+	return recovery, nil
+}
+
+// checks if the recovery still awaits approvals
+func (recovery *OwnershipRecovery) IsPending() bool {
+	return recovery.Status == AccessRequestPending
+}
+
+// records the approval of a member, returns false if the member already approved
+func (recovery *OwnershipRecovery) Approve(user string) bool {
+	if _, exist := recovery.Approvals[user]; exist {
+		return false
+	}
+	recovery.Approvals[user] = true
+	return true
+}
+
+// checks if enough time has passed since the proposal for ownership to be restored
+func (recovery *OwnershipRecovery) DelayElapsed(t time.Time) bool {
+	return !t.Before(recovery.Timestamp.Add(OwnershipRecoveryDelay))
+}
+
+// returns the number of approvals by members that are still eligible to approve
+func (recovery *OwnershipRecovery) CountApprovals(repo *Repository) int {
+	count := 0
+	for user := range recovery.Approvals {
+		if repo.Can(user, CapabilityRecoverOwnership) {
+			count++
+		}
+	}
+	return count
+}
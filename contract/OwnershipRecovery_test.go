@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRepositoryRecordOwnershipRecoveryStep(t *testing.T) {
+	repo := testRepo()
+	for _, user := range []string{"bob", "carol", "dave"} {
+		repo.UpdateAccess(user, MaintainAccess, "alice", testEpoch, time.Time{})
+	}
+	repo.UpdateAccess("erin", TriageAccess, "alice", testEpoch, time.Time{})
+
+	// owners do not take part in recoveries, a majority of the three maintainers is needed
+	if quorum := repo.GetOwnershipRecoveryQuorum(); quorum != 2 {
+		t.Fatalf("GetOwnershipRecoveryQuorum() = %d, want 2", quorum)
+	}
+
+	recovery, _ := CreateNewOwnershipRecovery("r1", "alice", "repo", "bob", "bob", testEpoch)
+	recovery.Approve("erin")
+	recovery.Approve("alice")
+	afterDelay := testEpoch.Add(OwnershipRecoveryDelay)
+
+	if repo.RecordOwnershipRecoveryStep(recovery, "bob", OwnershipRecovered, afterDelay) {
+		t.Fatalf("recovery approved by a single eligible member completed")
+	}
+
+	recovery.Approve("carol")
+	if repo.RecordOwnershipRecoveryStep(recovery, "carol", OwnershipRecovered, afterDelay.Add(-time.Second)) {
+		t.Fatalf("recovery completed before its delay")
+	}
+	if !repo.RecordOwnershipRecoveryStep(recovery, "carol", OwnershipRecovered, afterDelay) {
+		t.Fatalf("recovery with a quorum after its delay refused")
+	}
+	if !repo.IsOwner("bob") {
+		t.Fatalf("ownership was not restored to bob")
+	}
+
+	// an explicit quorum replaces the majority
+	repo.OwnershipRecoveryQuorum = 3
+	if quorum := repo.GetOwnershipRecoveryQuorum(); quorum != 3 {
+		t.Fatalf("GetOwnershipRecoveryQuorum() = %d, want 3", quorum)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+)
+
+// Private repos are stored in the implicit private data collection of their organization, which only
+// the organization's peers hold. The public ledger only keeps the hashes of the collection's writes.
+const implicitCollectionPrefix = "_implicit_org_"
+
+// returns the private data collection scoped to the organization with the MSP ID
+func GetOrgCollection(mspID string) string {
+	return implicitCollectionPrefix + mspID
+}
+
+// checks if the repo's content is stored in a private data collection
+func (repo *Repository) IsPrivate() bool {
+	return repo.PrivateCollection != ""
+}
+
+// returns the MSP ID of the organization whose collection stores the repo's content, empty for public repos
+func (repo *Repository) GetPrivateOrg() string {
+	return strings.TrimPrefix(repo.PrivateCollection, implicitCollectionPrefix)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"time"
+)
+
+// This enum represents the state of a pull request
+type PullRequestState string
+
+const (
+	PullRequestOpen   PullRequestState = "open"
+	PullRequestMerged PullRequestState = "merged"
+	PullRequestClosed PullRequestState = "closed"
+)
+
+// the head commit of the source branch of a pull request when it was opened or updated
+type PullRequestHead struct {
+	Commit    string    `json:"commit"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// This structure is modeling a proposal to merge a source branch, possibly of a fork of the repo,
+// into a target branch of the repo.
+type PullRequest struct {
+	ID               string            `json:"id"`
+	RepoAuthor       string            `json:"repoAuthor"`
+	RepoName         string            `json:"repoName"`
+	TargetBranch     string            `json:"targetBranch"`
+	SourceRepoAuthor string            `json:"sourceRepoAuthor"`
+	SourceRepoName   string            `json:"sourceRepoName"`
+	SourceBranch     string            `json:"sourceBranch"`
+	Author           string            `json:"author"`
+	Title            string            `json:"title"`
+	Description      string            `json:"description"`
+	State            PullRequestState  `json:"state"`
+	Heads            []PullRequestHead `json:"heads"`
+	Timestamp        time.Time         `json:"timestamp"`
+	ResolvedBy       string            `json:"resolvedBy,omitempty"`
+	ResolvedAt       time.Time         `json:"resolvedAt"`
+}
+
+// helper function that creates a new open pull request of the source branch at its head
+func CreateNewPullRequest(id string, repoAuthor string, repoName string, targetBranch string, sourceRepoAuthor string, sourceRepoName string, sourceBranch string,
+	author string, title string, description string, head string, timestamp time.Time) (PullRequest, error) {
+	var pullRequest PullRequest
+
+	pullRequest.ID = id
+	pullRequest.RepoAuthor = repoAuthor
+	pullRequest.RepoName = repoName
+	pullRequest.TargetBranch = targetBranch
+	pullRequest.SourceRepoAuthor = sourceRepoAuthor
+	pullRequest.SourceRepoName = sourceRepoName
+	pullRequest.SourceBranch = sourceBranch
+	pullRequest.Author = author
+	pullRequest.Title = title
+	pullRequest.Description = description
+	pullRequest.State = PullRequestOpen
+	pullRequest.Heads = []PullRequestHead{{head, timestamp}}
+	pullRequest.Timestamp = timestamp
+
+	return pullRequest, nil
+}
+
+// checks if the pull request can still be updated, closed or merged
+func (pullRequest *PullRequest) IsOpen() bool {
+	return pullRequest.State == PullRequestOpen
+}
+
+// checks if the source branch is in a fork of the repo
+func (pullRequest *PullRequest) FromFork() bool {
+	return pullRequest.SourceRepoAuthor != pullRequest.RepoAuthor || pullRequest.SourceRepoName != pullRequest.RepoName
+}
+
+// returns the head commit of the source branch at the last update
+func (pullRequest *PullRequest) Head() string {
+	if len(pullRequest.Heads) == 0 {
+		return ""
+	}
+	return pullRequest.Heads[len(pullRequest.Heads)-1].Commit
+}
+
+// records the head commit of the source branch at an update, returns false if it did not change
+func (pullRequest *PullRequest) RecordHead(head string, timestamp time.Time) bool {
+	if head == pullRequest.Head() {
+		return false
+	}
+	pullRequest.Heads = append(pullRequest.Heads, PullRequestHead{head, timestamp})
+	return true
+}
+
+// marks the pull request as merged or closed by the user
+func (pullRequest *PullRequest) Resolve(state PullRequestState, user string, timestamp time.Time) {
+	pullRequest.State = state
+	pullRequest.ResolvedBy = user
+	pullRequest.ResolvedAt = timestamp
+}
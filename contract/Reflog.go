@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// The ways the head of a branch can move
+type ReflogAction string
+
+const (
+	ReflogPush      ReflogAction = "push"
+	ReflogForcePush ReflogAction = "force-push"
+	ReflogMerge     ReflogAction = "merge"
+	ReflogRename    ReflogAction = "rename"
+	ReflogDelete    ReflogAction = "delete"
+	ReflogRestore   ReflogAction = "restore"
+)
+
+// This structure is modeling a movement of the head of a branch, which are recorded per branch.
+// The commits removed from the branch by the movement are kept so that it can be undone.
+type ReflogEntry struct {
+	ID             string       `json:"id"`
+	RepoAuthor     string       `json:"repoAuthor"`
+	RepoName       string       `json:"repoName"`
+	BranchName     string       `json:"branchName"`
+	Action         ReflogAction `json:"action"`
+	OldHead        string       `json:"oldHead"`
+	NewHead        string       `json:"newHead"`
+	OldBranchName  string       `json:"oldBranchName,omitempty"` // name of the branch before a rename
+	User           string       `json:"user"`
+	DroppedCommits []Commit     `json:"droppedCommits"`
+	Timestamp      time.Time    `json:"timestamp"`
+}
+
+// helper function that creates a new reflog entry
+func CreateNewReflogEntry(id string, repoAuthor string, repoName string, branchName string, action ReflogAction, oldHead string, newHead string, user string, timestamp time.Time) (ReflogEntry, error) {
+	var entry ReflogEntry
+
+	entry.ID = id
+	entry.RepoAuthor = repoAuthor
+	entry.RepoName = repoName
+	entry.BranchName = branchName
+	entry.Action = action
+	entry.OldHead = oldHead
+	entry.NewHead = newHead
+	entry.User = user
+	entry.DroppedCommits = make([]Commit, 0)
+	entry.Timestamp = timestamp
+
+	return entry, nil
+}
+
+// sorts the entries of a reflog from the newest to the oldest
+func SortReflog(entries []ReflogEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"time"
+)
+
+// This structure is modeling a pending transfer of a repo to another user or organization.
+// The transfer only happens once the receiving owner accepts it.
+type RepoTransfer struct {
+	RepoAuthor string    `json:"repoAuthor"`
+	RepoName   string    `json:"repoName"`
+	NewAuthor  string    `json:"newAuthor"`
+	Requester  string    `json:"requester"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// helper function that creates a new repo transfer
+func CreateNewRepoTransfer(repoAuthor string, repoName string, newAuthor string, requester string, timestamp time.Time) (RepoTransfer, error) {
+	var transfer RepoTransfer
+
+	transfer.RepoAuthor = repoAuthor
+	transfer.RepoName = repoName
+	transfer.NewAuthor = newAuthor
+	transfer.Requester = requester
+	transfer.Timestamp = timestamp
+
+	return transfer, nil
+}
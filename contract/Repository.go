@@ -5,19 +5,71 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strconv"
 	"time"
 )
 
 // This enum represents the type of access a user has for a repo
 type UserAccess int
 
+// The first four values keep their original meaning, ReadWriteAccess being the maintain role.
+// What each role permits is listed in the capability matrix.
 const (
 	ReadAccess      UserAccess = 1
 	ReadWriteAccess UserAccess = 2
 	OwnerAccess     UserAccess = 3
 	NoAccess        UserAccess = 4
+	TriageAccess    UserAccess = 5 // manage metadata only
+	WriteAccess     UserAccess = 6 // push to branches other than the default branch
+	AdminAccess     UserAccess = 7 // manage access and settings
+
+	MaintainAccess = ReadWriteAccess // manage branches and tags
 )
 
+// returns how much the access type permits, NoAccess being the lowest
+func (access UserAccess) Level() int {
+	switch access {
+	case ReadAccess:
+		return 1
+	case TriageAccess:
+		return 2
+	case WriteAccess:
+		return 3
+	case ReadWriteAccess:
+		return 4
+	case AdminAccess:
+		return 5
+	case OwnerAccess:
+		return 6
+	}
+	return 0
+}
+
+// returns the access type that permits the most out of a and b
+func MaxUserAccess(a UserAccess, b UserAccess) UserAccess {
+	if b.Level() > a.Level() {
+		return b
+	}
+	return a
+}
+
+// This enum represents who can read a repo without an explicit grant
+type RepoVisibility string
+
+const (
+	PrivateVisibility  RepoVisibility = "private"  // only users and teams granted access
+	InternalVisibility RepoVisibility = "internal" // any registered user
+	PublicVisibility   RepoVisibility = "public"   // anyone, including anonymous callers
+)
+
+// checks if the visibility is one of the known values
+func (visibility RepoVisibility) IsValid() bool {
+	return visibility == PrivateVisibility || visibility == InternalVisibility || visibility == PublicVisibility
+}
+
+// Access logs recording a visibility change use this principal as the authorized party
+const VisibilityLogPrincipal = "*"
+
 // A struct that contains the required data to keep track about who is responsible
 // of another user's access in the repository.
 type AccessLog struct {
@@ -25,6 +77,37 @@ type AccessLog struct {
 	Authorized string    `json:"authorized"`
 	Timestamp  time.Time `json:"timestamp"`
 	UserAccess `json:"userAccess"`
+	ExpiresAt  time.Time      `json:"expiresAt"`            // zero when the grant does not expire
+	Visibility RepoVisibility `json:"visibility,omitempty"` // set when the log records a visibility change
+
+	// set when the log records the outcome of an access request or invitation
+	RequestID     string              `json:"requestID,omitempty"`
+	RequestStatus AccessRequestStatus `json:"requestStatus,omitempty"`
+}
+
+// checks if the access granted by the log has lapsed at the provided time
+func (accessLog *AccessLog) IsExpired(t time.Time) bool {
+	return !accessLog.ExpiresAt.IsZero() && !t.Before(accessLog.ExpiresAt)
+}
+
+// An access log along with how long the grant it records remains valid
+type AccessLogValidity struct {
+	AccessLog
+	Expired           bool   `json:"expired"`
+	RemainingValidity string `json:"remainingValidity,omitempty"` // empty when the grant does not expire
+}
+
+// returns the validity of each access log at the provided time
+func GetAccessLogValidities(accessLogs []AccessLog, t time.Time) []AccessLogValidity {
+	validities := make([]AccessLogValidity, 0, len(accessLogs))
+	for _, accessLog := range accessLogs {
+		validity := AccessLogValidity{AccessLog: accessLog, Expired: accessLog.IsExpired(t)}
+		if !accessLog.ExpiresAt.IsZero() && !validity.Expired {
+			validity.RemainingValidity = accessLog.ExpiresAt.Sub(t).String()
+		}
+		validities = append(validities, validity)
+	}
+	return validities
 }
 
 type Repository struct {
@@ -35,16 +118,57 @@ type Repository struct {
 	Access       map[string]UserAccess `json:"access"` // Access control map: user -> [permissions]
 	Branches     map[string]Branch     `json:"branches"`
 	AccessLogs   []AccessLog           `json:"accessLogs"`
+	Teams        map[string]Team       `json:"-"`            // Teams granted access, by principal
+	AccessExpiry map[string]time.Time  `json:"accessExpiry"` // Expiry of time-limited grants: user -> expiry
+	CurrentTime  time.Time             `json:"-"`            // Time at which grants are evaluated, the transaction time
+	Visibility   RepoVisibility        `json:"visibility"`
+	Organization *Organization         `json:"-"` // Set when the repo is owned by an organization
+
+	// Branch protection rules, by pattern
+	BranchProtections map[string]BranchProtectionRule `json:"branchProtections"`
+
+	// Tag protection rules, by pattern
+	TagProtections map[string]TagProtectionRule `json:"tagProtections"`
+
+	// When set, every pushed commit must carry a valid signature of the pusher
+	RequireSignedCommits bool `json:"requireSignedCommits"`
+
+	// Number of members allowed to recover ownership needed to restore it, a majority of them when 0
+	OwnershipRecoveryQuorum int `json:"ownershipRecoveryQuorum"`
+
+	// Number of approvals from maintainers or higher needed to merge a pull request into a protected branch
+	RequiredApprovals int `json:"requiredApprovals"`
+
+	// MSP IDs of the organizations whose peers must endorse writes to the repo's keys
+	EndorsingOrgs []string `json:"endorsingOrgs"`
+
+	// Private data collection storing the repo's content, empty when it is stored in the world state
+	PrivateCollection string `json:"privateCollection,omitempty"`
+
+	// Epoch of the key encrypting the repo's files on IPFS, 0 when they are not encrypted
+	KeyEpoch int `json:"keyEpoch"`
 }
 
 // This function takes a json string that represents the marshalling of Repo
 // and returns a Repo.
-// The returned data is valid and consistent
+// The returned data is valid and consistent. The private data collection and the key epoch are never taken
+// from the json, the collection is derived from the organization storing the repo and encryption is enabled separately.
+// Neither are the access logs: the author is the only owner of a new repo and their ownership does not expire.
 func UnmarshalRepo(objectString string, createdTime time.Time) (Repository, error) {
 	var unmarashaledRepo Repository
 	json.Unmarshal([]byte(objectString), &unmarashaledRepo)
 
-	repo, _ := CreateNewRepo(unmarashaledRepo.Name, unmarashaledRepo.Author, unmarashaledRepo.DirectoryCID, nil, unmarashaledRepo.AccessLogs, createdTime)
+	repo, _ := CreateNewRepo(unmarashaledRepo.Name, unmarashaledRepo.Author, unmarashaledRepo.DirectoryCID, nil, nil, createdTime)
+	repo.RequireSignedCommits = unmarashaledRepo.RequireSignedCommits
+	repo.OwnershipRecoveryQuorum = unmarashaledRepo.OwnershipRecoveryQuorum
+	repo.RequiredApprovals = unmarashaledRepo.RequiredApprovals
+	repo.EndorsingOrgs = unmarashaledRepo.EndorsingOrgs
+	for pattern, rule := range unmarashaledRepo.BranchProtections {
+		repo.BranchProtections[pattern] = rule
+	}
+	for pattern, rule := range unmarashaledRepo.TagProtections {
+		repo.TagProtections[pattern] = rule
+	}
 
 	for _, branch := range unmarashaledRepo.Branches {
 		newBranch, _ := CreateNewBranch(branch.Name, nil)
@@ -62,7 +186,10 @@ func UnmarshalRepo(objectString string, createdTime time.Time) (Repository, erro
 		})
 
 		for i := 0; i < len(logsList); i++ {
-			repo.AddCommit(logsList[i], newBranch.Name, false)
+			_, err := repo.AddCommit(logsList[i], newBranch.Name, false, false)
+			if err != nil {
+				return repo, err
+			}
 		}
 	}
 
@@ -74,54 +201,123 @@ func (repo *Repository) UpdateRepoName(newName string) {
 	repo.Name = newName
 }
 
-// returns the current access type of the specified user's userName
+// returns the current effective access type of the specified user's userName,
+// which is the highest of the user's direct grant and the grants of the teams the user is a member of.
+// Expired grants are treated as NoAccess.
+// Public repos grant ReadAccess to anyone and internal repos to any registered user, an empty user
+// being an anonymous caller.
 func (repo *Repository) GetUserAccess(user string) UserAccess {
-	if val, exist := repo.Access[user]; exist {
-		return val
+	access := repo.getVisibilityAccess(user)
+	if user != "" {
+		access = MaxUserAccess(access, repo.getPrincipalAccess(user))
+	}
+
+	// owners of the organization owning the repo own the repo
+	if repo.Organization != nil && repo.Organization.IsOwner(user) {
+		access = OwnerAccess
+	}
+
+	for principal := range repo.Access {
+		if team, exist := repo.Teams[principal]; exist && team.HasMember(user) {
+			access = MaxUserAccess(access, repo.getPrincipalAccess(principal))
+		}
+	}
+
+	return access
+}
+
+// returns the access type granted to the user by the visibility of the repo
+func (repo *Repository) getVisibilityAccess(user string) UserAccess {
+	if repo.Visibility == PublicVisibility || (repo.Visibility == InternalVisibility && user != "") {
+		return ReadAccess
 	}
 	return NoAccess
 }
 
+// returns the access type granted directly to a user or team, NoAccess once the grant has expired
+func (repo *Repository) getPrincipalAccess(principal string) UserAccess {
+	val, exist := repo.Access[principal]
+	if !exist {
+		return NoAccess
+	}
+
+	if expiry, expires := repo.AccessExpiry[principal]; expires && !repo.CurrentTime.Before(expiry) {
+		return NoAccess
+	}
+
+	return val
+}
+
+// adds a team that has been granted access so that its members' effective access can be resolved
+func (repo *Repository) AddTeam(team Team) {
+	repo.Teams[team.Principal()] = team
+}
+
+// checks if the effective access of the mentioned user grants the capability
+func (repo *Repository) Can(user string, capability Capability) bool {
+	return repo.GetUserAccess(user).HasCapability(capability)
+}
+
 // checks if the mentioned user is authorized to do read for the repository.
-// anyone included in the repo can read / write
+// anyone included in the repo can read
 func (repo *Repository) CanRead(user string) bool {
-	if val, exist := repo.Access[user]; exist {
-		return val == ReadAccess || val == ReadWriteAccess || val == OwnerAccess
-	}
-	return false
+	return repo.Can(user, CapabilityRead)
 }
 
-// checks if the mentioned user is authorized to do read/write for the repository.
-// Collaborators and owners can edit the repository
+// checks if the mentioned user is authorized to push to the mentioned branch of the repository.
+// Members with WriteAccess can only push to branches other than the default branch
+func (repo *Repository) CanPush(user string, branchName string) bool {
+	return repo.Can(user, PushCapability(branchName))
+}
+
+// checks if the mentioned user is authorized to manage branches of the repository.
+// Maintainers, admins and owners can edit the repository
 func (repo *Repository) CanEdit(user string) bool {
-	if val, exist := repo.Access[user]; exist {
-		return val == ReadWriteAccess || val == OwnerAccess
-	}
-	return false
+	return repo.Can(user, CapabilityManageBranches)
 }
 
-// checks if the mentioned user is authorized to authorize or revoke ReadWriteAccess for the repository.
-// Only owners can auth
+// checks if the mentioned user owns the repository.
+// Only owners can rename, delete or transfer the repository and grant or revoke OwnerAccess
 func (repo *Repository) IsOwner(user string) bool {
-	if val, exist := repo.Access[user]; exist {
-		return val == OwnerAccess
+	return repo.GetUserAccess(user) == OwnerAccess
+}
+
+// checks if the authorizer may set the access of the authorized principal to the provided access type.
+// Admins manage access except for owners, whose access only owners can grant or revoke
+func (repo *Repository) CanGrant(authorizer string, authorized string, userAccess UserAccess) bool {
+	if !repo.Can(authorizer, CapabilityManageAccess) {
+		return false
 	}
-	return false
+	if userAccess == OwnerAccess || repo.Access[authorized] == OwnerAccess {
+		return repo.Can(authorizer, CapabilityAdminister)
+	}
+	return true
 }
 
 // It does the required data writing work to update a user's
 // access type in case, the user access update is valid.
-func (repo *Repository) UpdateAccess(authorized string, userAccess UserAccess, authorizer string, timestamp time.Time) bool {
+// A zero expiresAt grants access that does not expire.
+func (repo *Repository) UpdateAccess(authorized string, userAccess UserAccess, authorizer string, timestamp time.Time, expiresAt time.Time) bool {
 
-	if repo.IsOwner(authorizer) {
+	if repo.CanGrant(authorizer, authorized, userAccess) {
 		if val, exist := repo.Access[authorized]; exist {
-			if val == userAccess {
+			if val == userAccess && repo.AccessExpiry[authorized].Equal(expiresAt) {
 				return false
 			}
 		}
 
 		// A user cannot change their own access
-		if authorizer == authorized {
+		if authorizer == authorized || authorized == VisibilityLogPrincipal {
+			return false
+		}
+
+		// An expiry must be in the future
+		if !expiresAt.IsZero() && !expiresAt.After(timestamp) {
+			return false
+		}
+
+		// Ownership cannot lapse, or the repo could be left without owners
+		if userAccess == OwnerAccess && !expiresAt.IsZero() {
 			return false
 		}
 
@@ -130,9 +326,15 @@ func (repo *Repository) UpdateAccess(authorized string, userAccess UserAccess, a
 		accessLog.Authorized = authorized
 		accessLog.Timestamp = timestamp
 		accessLog.UserAccess = userAccess
+		accessLog.ExpiresAt = expiresAt
+
+		// The repo must keep at least one owner
+		if len(repo.getOwnersAfter(accessLog)) == 0 {
+			return false
+		}
 
 		repo.AccessLogs = append(repo.AccessLogs, accessLog)
-		repo.Access[authorized] = userAccess
+		repo.applyAccessLog(accessLog)
 
 		return true
 	}
@@ -140,6 +342,223 @@ func (repo *Repository) UpdateAccess(authorized string, userAccess UserAccess, a
 	return false
 }
 
+// It does the required data writing work to update the visibility of the repo
+// in case the authorizer can manage its settings and the visibility changes.
+func (repo *Repository) UpdateVisibility(visibility RepoVisibility, authorizer string, timestamp time.Time) bool {
+
+	if !visibility.IsValid() || visibility == repo.Visibility || !repo.Can(authorizer, CapabilityManageSettings) {
+		return false
+	}
+
+	var accessLog AccessLog
+	accessLog.Authorizer = authorizer
+	accessLog.Authorized = VisibilityLogPrincipal
+	accessLog.Timestamp = timestamp
+	accessLog.UserAccess = NoAccess
+	accessLog.Visibility = visibility
+
+	repo.AccessLogs = append(repo.AccessLogs, accessLog)
+	repo.applyAccessLog(accessLog)
+
+	return true
+}
+
+// It does the required data writing work to record the outcome of an access request or invitation.
+// Approved requests and accepted invitations update the user's access, authorized by the approving owner
+// or the inviting owner respectively, while denied and declined ones are only logged.
+func (repo *Repository) RecordAccessRequestOutcome(request AccessRequest) bool {
+
+	if request.Status.Grants() {
+		authorizer := request.ResolvedBy
+		if request.Kind == AccessRequestKindInvitation {
+			authorizer = request.Requester
+		}
+
+		if !repo.UpdateAccess(request.User, request.UserAccess, authorizer, request.ResolvedAt, time.Time{}) {
+			return false
+		}
+
+		repo.AccessLogs[len(repo.AccessLogs)-1].RequestID = request.ID
+		repo.AccessLogs[len(repo.AccessLogs)-1].RequestStatus = request.Status
+		return true
+	}
+
+	if request.Status != AccessRequestDenied && request.Status != AccessRequestDeclined {
+		return false
+	}
+
+	var accessLog AccessLog
+	accessLog.Authorizer = request.ResolvedBy
+	accessLog.Authorized = request.User
+	accessLog.Timestamp = request.ResolvedAt
+	accessLog.UserAccess = request.UserAccess
+	accessLog.RequestID = request.ID
+	accessLog.RequestStatus = request.Status
+
+	repo.AccessLogs = append(repo.AccessLogs, accessLog)
+
+	return true
+}
+
+// It does the required data writing work to transfer the repo to a new author,
+// which becomes an owner of the repo while the previous author is given previousAuthorAccess.
+// The access logs are kept.
+func (repo *Repository) Transfer(newAuthor string, authorizer string, timestamp time.Time, previousAuthorAccess UserAccess) bool {
+
+	if !repo.Can(authorizer, CapabilityAdminister) || newAuthor == repo.Author {
+		return false
+	}
+
+	if previousAuthorAccess != NoAccess && previousAuthorAccess.Level() == 0 {
+		return false
+	}
+
+	var accessLog AccessLog
+	accessLog.Authorizer = authorizer
+	accessLog.Authorized = newAuthor
+	accessLog.Timestamp = timestamp
+	accessLog.UserAccess = OwnerAccess
+
+	repo.AccessLogs = append(repo.AccessLogs, accessLog)
+	repo.applyAccessLog(accessLog)
+
+	var previousAuthorLog AccessLog
+	previousAuthorLog.Authorizer = authorizer
+	previousAuthorLog.Authorized = repo.Author
+	previousAuthorLog.Timestamp = timestamp
+	previousAuthorLog.UserAccess = previousAuthorAccess
+
+	repo.AccessLogs = append(repo.AccessLogs, previousAuthorLog)
+	repo.applyAccessLog(previousAuthorLog)
+
+	// the owners of the previous organization no longer own the repo
+	repo.Author = newAuthor
+	repo.Organization = nil
+
+	return true
+}
+
+// returns the users whose effective access is the provided access type
+func (repo *Repository) GetUsersWithAccess(access UserAccess) []string {
+	return repo.getUsersWhere(func(user string) bool {
+		return repo.GetUserAccess(user) == access
+	})
+}
+
+// returns the users whose effective access grants the capability
+func (repo *Repository) GetUsersWithCapability(capability Capability) []string {
+	return repo.getUsersWhere(func(user string) bool {
+		return repo.Can(user, capability)
+	})
+}
+
+// returns the users granted access, directly, through a team or through the organization, that match the filter
+func (repo *Repository) getUsersWhere(filter func(user string) bool) []string {
+	candidates := make(map[string]bool)
+	for principal := range repo.Access {
+		if _, _, isTeam := ParseTeamPrincipal(principal); !isTeam {
+			candidates[principal] = true
+		}
+	}
+	for principal := range repo.Access {
+		if team, exist := repo.Teams[principal]; exist {
+			for member := range team.Members {
+				candidates[member] = true
+			}
+		}
+	}
+	if repo.Organization != nil {
+		delete(candidates, repo.Organization.Name)
+		for owner := range repo.Organization.Owners {
+			candidates[owner] = true
+		}
+	}
+
+	users := make([]string, 0)
+	for user := range candidates {
+		if filter(user) {
+			users = append(users, user)
+		}
+	}
+	sort.Strings(users)
+
+	return users
+}
+
+// returns the users that would own the repo once the access log is applied
+func (repo *Repository) getOwnersAfter(accessLog AccessLog) []string {
+	updatedRepo := *repo
+	updatedRepo.Access = make(map[string]UserAccess)
+	for principal, access := range repo.Access {
+		updatedRepo.Access[principal] = access
+	}
+	updatedRepo.AccessExpiry = make(map[string]time.Time)
+	for principal, expiry := range repo.AccessExpiry {
+		updatedRepo.AccessExpiry[principal] = expiry
+	}
+
+	updatedRepo.applyAccessLog(accessLog)
+
+	return updatedRepo.GetUsersWithAccess(OwnerAccess)
+}
+
+// returns the number of approvals needed to restore ownership of the repo
+func (repo *Repository) GetOwnershipRecoveryQuorum() int {
+	if repo.OwnershipRecoveryQuorum > 0 {
+		return repo.OwnershipRecoveryQuorum
+	}
+	return len(repo.GetUsersWithCapability(CapabilityRecoverOwnership))/2 + 1
+}
+
+// It does the required data writing work to record a step of an ownership recovery.
+// Once the recovery is complete, the new owner is granted OwnerAccess by the last approver.
+func (repo *Repository) RecordOwnershipRecoveryStep(recovery OwnershipRecovery, actor string, status AccessRequestStatus, timestamp time.Time) bool {
+
+	var accessLog AccessLog
+	accessLog.Authorizer = actor
+	accessLog.Authorized = recovery.NewOwner
+	accessLog.Timestamp = timestamp
+	accessLog.UserAccess = OwnerAccess
+	accessLog.RequestID = recovery.ID
+	accessLog.RequestStatus = status
+
+	if status == OwnershipRecovered {
+		if recovery.CountApprovals(repo) < repo.GetOwnershipRecoveryQuorum() || !recovery.DelayElapsed(timestamp) {
+			return false
+		}
+	}
+
+	repo.AccessLogs = append(repo.AccessLogs, accessLog)
+	repo.applyAccessLog(accessLog)
+
+	return true
+}
+
+// returns the most recently recorded access log
+func (repo *Repository) LastAccessLog() AccessLog {
+	return repo.AccessLogs[len(repo.AccessLogs)-1]
+}
+
+// sets the access of the principal, or the visibility of the repo, recorded in the log
+func (repo *Repository) applyAccessLog(accessLog AccessLog) {
+	if accessLog.Visibility != "" {
+		repo.Visibility = accessLog.Visibility
+		return
+	}
+
+	// denied requests and declined invitations leave access unchanged
+	if accessLog.RequestStatus != "" && !accessLog.RequestStatus.Grants() {
+		return
+	}
+
+	repo.Access[accessLog.Authorized] = accessLog.UserAccess
+	if accessLog.ExpiresAt.IsZero() {
+		delete(repo.AccessExpiry, accessLog.Authorized)
+	} else {
+		repo.AccessExpiry[accessLog.Authorized] = accessLog.ExpiresAt
+	}
+}
+
 // checks if the provided hash has belonged to one of the repo's branches
 func (repo *Repository) CommitExists(commitHash string) bool {
 	_, exist := repo.CommitHashes[commitHash]
@@ -173,31 +592,247 @@ func (repo *Repository) AddCommitHashes(commits []Commit) bool {
 	return true
 }
 
-// checks that all hash parents are hashes in the repository
-func (repo *Repository) ValidCommit(commit Commit, branchName string) (bool, error) {
+// Checks if a commit can be added to a branch of the repo, validating every parent against the commits of the repo.
+// allowNewRoot lets a commit without parents start a new history in a non-empty branch.
+func (repo *Repository) ValidCommit(commit Commit, branchName string, allowNewRoot bool) (bool, error) {
 
-	if repo.BranchExists(branchName) {
-		branch := repo.Branches[branchName]
-		if len(repo.CommitHashes) == 0 {
-			return true, nil
+	if !repo.BranchExists(branchName) {
+		return false, errors.New("Branch " + branchName + " does not exist!")
+	}
+
+	// an empty repo only accepts root commits, as any parent would be dangling
+	branch := repo.Branches[branchName]
+	return branch.ValidCommit(commit, repo.GetCommits(), allowNewRoot)
+}
+
+// verifies the signatures of commits pushed by the user owning keys and sets their Verified flag.
+// A commit with an invalid signature is rejected, as is an unsigned commit when the repo requires signed commits.
+// Commits new to the repo must be signed with a key that is still valid at the time of the push.
+func (repo *Repository) VerifyCommits(commits []Commit, pusher string, keys []UserKey) ([]Commit, error) {
+	for i := range commits {
+		commits[i].AuthorID = pusher
+
+		if !commits[i].IsSigned() && repo.RequireSignedCommits {
+			return commits, errors.New("Commit " + commits[i].Hash + " is not signed but repo " + repo.Name + " requires signed commits!")
 		}
-		if valid, _ := branch.ValidCommit(commit); valid {
 
-			allParentsAreHashes := true
-			for _, parentHash := range commit.ParentHashes {
-				allParentsAreHashes = allParentsAreHashes && repo.CommitExists(parentHash)
-				if !allParentsAreHashes {
-					break
-				}
-			}
+		var err error
+		if repo.CommitExists(commits[i].Hash) {
+			err = commits[i].VerifySignature(keys)
+		} else {
+			err = commits[i].VerifyPushedSignature(keys, repo.CurrentTime)
+		}
+		if err != nil {
+			return commits, err
+		}
+	}
+
+	return commits, nil
+}
+
+// returns every stored commit of the repo by hash, whichever branch holds it
+func (repo *Repository) GetCommits() map[string]Commit {
+	commits := make(map[string]Commit)
+	for _, branch := range repo.Branches {
+		for hash, commit := range branch.Commits {
+			commits[hash] = commit
+		}
+	}
+
+	return commits
+}
 
-			if allParentsAreHashes {
-				return true, nil
+// returns a stored commit of the repo, whichever branch holds it
+func (repo *Repository) GetCommit(commitHash string) (Commit, bool) {
+	for _, branch := range repo.Branches {
+		if commit, exist := branch.Commits[commitHash]; exist {
+			return commit, true
+		}
+	}
+
+	var commit Commit
+	return commit, false
+}
+
+// verifies the commits of a branch that is added to the repo.
+// Commits already stored in the repo keep their stored signature verification,
+// new commits are verified against the keys of the user adding the branch.
+func (repo *Repository) VerifyBranchCommits(branch Branch, pusher string, keys []UserKey) (Branch, error) {
+	for hash, commit := range branch.Commits {
+		if storedCommit, exist := repo.GetCommit(hash); exist {
+			branch.Commits[hash] = storedCommit
+			continue
+		}
+
+		verifiedCommits, err := repo.VerifyCommits([]Commit{commit}, pusher, keys)
+		if err != nil {
+			return branch, err
+		}
+		branch.Commits[hash] = verifiedCommits[0]
+	}
+
+	return branch, nil
+}
+
+// Checks the commits of a branch that is added to the repo which are new to the repo, from the oldest to the newest.
+// Every parent must be a commit of the repo or an earlier new commit, and be older than its child.
+// allowNewRoot lets new commits without parents start a new history, which is always allowed in an empty repo.
+func (repo *Repository) ValidBranchCommits(branch Branch, allowNewRoot bool) error {
+	knownCommits := repo.GetCommits()
+	newCommits := make([]Commit, 0)
+	for hash, commit := range branch.Commits {
+		if _, exist := knownCommits[hash]; !exist {
+			newCommits = append(newCommits, commit)
+		}
+	}
+
+	// parents are older than their children
+	sort.Slice(newCommits, func(i, j int) bool {
+		if newCommits[i].Timestamp.UnixMilli() != newCommits[j].Timestamp.UnixMilli() {
+			return newCommits[i].Timestamp.UnixMilli() < newCommits[j].Timestamp.UnixMilli()
+		}
+		return newCommits[i].Hash < newCommits[j].Hash
+	})
+
+	// the history shares its commits with knownCommits, so that each checked commit becomes known to the next ones
+	history, _ := CreateNewBranch(branch.Name, knownCommits)
+	for _, commit := range newCommits {
+		if _, err := history.ValidCommit(commit, knownCommits, allowNewRoot); err != nil {
+			return err
+		}
+		knownCommits[commit.Hash] = commit
+	}
+
+	return nil
+}
+
+// sets a branch protection rule, replacing any rule with the same pattern
+func (repo *Repository) SetBranchProtection(rule BranchProtectionRule) {
+	repo.BranchProtections[rule.Pattern] = rule
+}
+
+// checks if the user may set the protection rule, or remove it, replacing the rule with the same pattern.
+// Admins manage the rules except for those restricting pushes to owners, which only owners can add, change or remove
+func (repo *Repository) CanProtectBranch(user string, rule BranchProtectionRule) bool {
+	if !repo.Can(user, CapabilityManageSettings) {
+		return false
+	}
+	if rule.OwnerOnlyPush || repo.BranchProtections[rule.Pattern].OwnerOnlyPush {
+		return repo.IsOwner(user)
+	}
+	return true
+}
+
+// returns the branch protection rules that apply to the mentioned branch
+func (repo *Repository) GetBranchProtections(branchName string) []BranchProtectionRule {
+	rules := make([]BranchProtectionRule, 0)
+	for _, rule := range repo.BranchProtections {
+		if rule.Matches(branchName) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// checks that no protection rule forbids deleting the branch
+func (repo *Repository) CheckBranchDeletion(branchName string) error {
+	for _, rule := range repo.GetBranchProtections(branchName) {
+		if rule.NoDeletion {
+			return rule.violation(branchName, "branch cannot be deleted")
+		}
+	}
+	return nil
+}
+
+// checks that no protection rule forbids renaming the branch
+func (repo *Repository) CheckBranchRename(branchName string) error {
+	for _, rule := range repo.GetBranchProtections(branchName) {
+		if rule.NoRename {
+			return rule.violation(branchName, "branch cannot be renamed")
+		}
+	}
+	return nil
+}
+
+// checks that the protection rules of the branch allow the user to push the commits to it.
+// When the repo requires approvals, commits only reach a protected branch through the merge of an approved
+// pull request, which approvedMerge tells.
+func (repo *Repository) CheckBranchPush(branchName string, user string, commits []Commit, approvedMerge bool) error {
+	rules := repo.GetBranchProtections(branchName)
+	if repo.RequiredApprovals > 0 && len(rules) > 0 && !approvedMerge {
+		return errors.New("Branch " + branchName + " is protected, it can only be changed by merging a pull request with " +
+			strconv.Itoa(repo.RequiredApprovals) + " approvals")
+	}
+
+	for _, rule := range rules {
+		if rule.OwnerOnlyPush && !repo.IsOwner(user) {
+			return rule.violation(branchName, "only owners can push")
+		}
+
+		for _, commit := range commits {
+			if rule.RequireSignedCommits && !commit.Verified {
+				return rule.violation(branchName, "commit "+commit.Hash+" is not signed with a verified signature")
+			}
+			if rule.RequireLinearHistory && len(commit.ParentHashes) > 1 {
+				return rule.violation(branchName, "commit "+commit.Hash+" is a merge commit but linear history is required")
 			}
 		}
 	}
+	return nil
+}
 
-	return false, nil
+// sets a tag protection rule, replacing the rule with the same pattern
+func (repo *Repository) SetTagProtection(rule TagProtectionRule) {
+	repo.TagProtections[rule.Pattern] = rule
+}
+
+// checks if the user may set the protection rule, or remove it, replacing the rule with the same pattern.
+// Admins manage the rules except for owner-only ones, which only owners can add, change or remove
+func (repo *Repository) CanProtectTag(user string, rule TagProtectionRule) bool {
+	if !repo.Can(user, CapabilityManageSettings) {
+		return false
+	}
+	if rule.OwnerOnly || repo.TagProtections[rule.Pattern].OwnerOnly {
+		return repo.IsOwner(user)
+	}
+	return true
+}
+
+// returns the tag protection rules that apply to the mentioned tag
+func (repo *Repository) GetTagProtections(tagName string) []TagProtectionRule {
+	rules := make([]TagProtectionRule, 0)
+	for _, rule := range repo.TagProtections {
+		if rule.Matches(tagName) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// checks that the protection rules of the tag allow the user to create it, or to move it when it exists
+func (repo *Repository) CheckTagUpdate(tagName string, user string, exists bool) error {
+	for _, rule := range repo.GetTagProtections(tagName) {
+		if rule.OwnerOnly && !repo.IsOwner(user) {
+			return rule.violation(tagName, "only owners can create or move the tag")
+		}
+		if rule.NoMove && exists {
+			return rule.violation(tagName, "tag cannot be moved")
+		}
+	}
+	return nil
+}
+
+// checks that the protection rules of the tag allow the user to delete it
+func (repo *Repository) CheckTagDeletion(tagName string, user string) error {
+	for _, rule := range repo.GetTagProtections(tagName) {
+		if rule.OwnerOnly && !repo.IsOwner(user) {
+			return rule.violation(tagName, "only owners can delete the tag")
+		}
+		if rule.NoDeletion {
+			return rule.violation(tagName, "tag cannot be deleted")
+		}
+	}
+	return nil
 }
 
 // helper function that is needed to create a new Repo instance
@@ -213,19 +848,30 @@ func CreateNewRepo(name string, author string, directoryCID string, branches map
 		repo.AccessLogs = accessLogs
 	} else {
 		repo.AccessLogs = make([]AccessLog, 0)
-		repo.AccessLogs = append(repo.AccessLogs, AccessLog{repo.Author, repo.Author, createdTime, OwnerAccess})
+		repo.AccessLogs = append(repo.AccessLogs, AccessLog{Authorizer: repo.Author, Authorized: repo.Author, Timestamp: createdTime, UserAccess: OwnerAccess})
 	}
 
+	// the latest log of each principal determines its access
+	sort.SliceStable(repo.AccessLogs, func(i, j int) bool {
+		return repo.AccessLogs[i].Timestamp.UnixNano() < repo.AccessLogs[j].Timestamp.UnixNano()
+	})
+
+	repo.CurrentTime = createdTime
+	repo.Visibility = PrivateVisibility
+	repo.Teams = make(map[string]Team)
+	repo.BranchProtections = make(map[string]BranchProtectionRule)
+	repo.TagProtections = make(map[string]TagProtectionRule)
 	repo.Access = make(map[string]UserAccess)
+	repo.AccessExpiry = make(map[string]time.Time)
 	for _, accessLog := range repo.AccessLogs {
-		repo.Access[accessLog.Authorized] = accessLog.UserAccess
+		repo.applyAccessLog(accessLog)
 	}
 
 	if branches == nil {
 		// Will only contain main branch
 		repo.Branches = make(map[string]Branch)
 
-		var mainBranch = Branch{"main", make(map[string]Commit)}
+		var mainBranch = Branch{DefaultBranchName, "", make(map[string]Commit)}
 		repo.Branches[mainBranch.Name] = mainBranch
 		fmt.Println("main branch is created!")
 	} else {
@@ -263,12 +909,17 @@ func (repo *Repository) AddBranch(branch Branch, setIfExists bool) (bool, error)
 }
 
 // Adds a commit to a branch if it creates a new valid state
-func (repo *Repository) AddCommit(commit Commit, branchName string, passValidation bool) (bool, error) {
+func (repo *Repository) AddCommit(commit Commit, branchName string, passValidation bool, allowNewRoot bool) (bool, error) {
 
-	if valid, _ := repo.ValidCommit(commit, branchName); valid || passValidation {
+	valid := passValidation
+	var err error
+	if !valid {
+		valid, err = repo.ValidCommit(commit, branchName, allowNewRoot)
+	}
+	if valid {
 
 		branch := repo.Branches[branchName]
-		if done, _ := branch.AddCommit(commit, passValidation); done {
+		if done, _ := branch.AddCommit(commit, true); done {
 			repo.Branches[branchName] = branch
 			repo.AddCommitHash(commit)
 			return true, nil
@@ -276,14 +927,17 @@ func (repo *Repository) AddCommit(commit Commit, branchName string, passValidati
 
 	}
 
-	return false, nil
+	return false, err
 }
 
 // Adds a list of commits one by one to a branch if it creates a new valid state
-func (repo *Repository) AddCommits(commits []Commit, branchName string, passValidation bool) (bool, error) {
+func (repo *Repository) AddCommits(commits []Commit, branchName string, passValidation bool, allowNewRoot bool) (bool, error) {
 	for _, commit := range commits {
-		pass, _ := repo.AddCommit(commit, branchName, passValidation)
+		pass, err := repo.AddCommit(commit, branchName, passValidation, allowNewRoot)
 		if !pass {
+			if err != nil {
+				return false, err
+			}
 			return false, errors.New("Could not add commit " + commit.Hash + "!")
 		}
 	}
@@ -291,13 +945,135 @@ func (repo *Repository) AddCommits(commits []Commit, branchName string, passVali
 	return true, nil
 }
 
+// Pushes a list of commits to a branch, which must fast-forward the branch: its head moves to the only pushed
+// commit that is not a parent of another pushed commit, from which every pushed commit and the old head must be reachable.
+// When expectedHead is given the push fails if the branch no longer points at it, so that concurrent pushes
+// from the same state cannot both be accepted. allowNewRoot lets pushed commits without parents start a new history,
+// which must be merged into the history of the branch unless the branch is empty. Replacing the history of a branch
+// takes a force-push.
+func (repo *Repository) PushCommits(commits []Commit, branchName string, expectedHead string, allowNewRoot bool) error {
+	if !repo.BranchExists(branchName) {
+		return errors.New("Branch " + branchName + " does not exist!")
+	}
+
+	oldHead := repo.Branches[branchName].Head
+	if expectedHead != "" && expectedHead != oldHead {
+		return errors.New("Branch " + branchName + " has moved to " + oldHead + ", expected " + expectedHead + ". Please pull the branch first.")
+	}
+
+	if len(commits) == 0 {
+		return errors.New("Could not find any commits")
+	}
+
+	pushedCommits := make(map[string]Commit)
+	for _, commit := range commits {
+		pushedCommits[commit.Hash] = commit
+	}
+	push, _ := CreateNewBranch(branchName, pushedCommits)
+	if err := push.ResolveHead(); err != nil {
+		return errors.New("Push to branch " + branchName + " does not fast-forward: " + err.Error())
+	}
+
+	if _, err := repo.AddCommits(commits, branchName, false, allowNewRoot); err != nil {
+		return err
+	}
+
+	branch := repo.Branches[branchName]
+	branch.Head = push.Head
+	repo.Branches[branchName] = branch
+
+	if oldHead != "" && !repo.IsAncestor(oldHead, push.Head) {
+		return errors.New("Push to branch " + branchName + " does not fast-forward from " + oldHead + "!")
+	}
+
+	return nil
+}
+
+// Points a branch at a head and replaces its commits with the commits reachable from it, creating the branch
+// if it does not exist. knownCommits must hold the whole history of the head.
+// returns the commits that are no longer part of the branch
+func (repo *Repository) SetBranchHead(branchName string, head string, knownCommits map[string]Commit) ([]Commit, error) {
+	dropped := make([]Commit, 0)
+
+	if _, exist := knownCommits[head]; !exist {
+		return dropped, errors.New("Commit " + head + " does not exist in the repo!")
+	}
+
+	commits := make(map[string]Commit)
+	for hash := range GetAncestors(head, knownCommits) {
+		commit, exist := knownCommits[hash]
+		if !exist {
+			return dropped, errors.New("Commit " + hash + " in the history of " + head + " does not exist in the repo!")
+		}
+		commits[hash] = commit
+	}
+
+	if branch, exist := repo.Branches[branchName]; exist {
+		for hash, commit := range branch.Commits {
+			if _, kept := commits[hash]; !kept {
+				dropped = append(dropped, commit)
+			}
+		}
+	}
+
+	branch, _ := CreateNewBranch(branchName, commits)
+	branch.Head = head
+	repo.Branches[branchName] = branch
+	repo.AddCommitHashes(branch.CommitList())
+
+	return dropped, nil
+}
+
+// Replaces the history of a branch with the history of a new head, which may drop commits from the branch.
+// The pushed commits must have their parents among the commits of the repo or earlier pushed commits,
+// and be newer than them. When expectedHead is given the branch must still point at it.
+// returns the commits that are no longer part of the branch
+func (repo *Repository) ForcePushCommits(commits []Commit, branchName string, head string, expectedHead string) ([]Commit, error) {
+	if !repo.BranchExists(branchName) {
+		return nil, errors.New("Branch " + branchName + " does not exist!")
+	}
+
+	oldHead := repo.Branches[branchName].Head
+	if expectedHead != "" && expectedHead != oldHead {
+		return nil, errors.New("Branch " + branchName + " has moved to " + oldHead + ", expected " + expectedHead + ". Please pull the branch first.")
+	}
+
+	knownCommits := repo.GetCommits()
+	for _, commit := range commits {
+		for _, hash := range commit.ParentHashes {
+			parent, exist := knownCommits[hash]
+			if !exist {
+				return nil, errors.New("Parent commit " + hash + " of commit " + commit.Hash + " does not exist in the repo!")
+			}
+			if parent.Timestamp.UnixMilli() >= commit.Timestamp.UnixMilli() {
+				return nil, errors.New("Parent commit " + hash + " is not older than commit " + commit.Hash + "!")
+			}
+		}
+		knownCommits[commit.Hash] = commit
+	}
+
+	dropped, err := repo.SetBranchHead(branchName, head, knownCommits)
+	if err != nil {
+		return nil, err
+	}
+
+	reachable := repo.Branches[branchName].Commits
+	for _, commit := range commits {
+		if _, exist := reachable[commit.Hash]; !exist {
+			return nil, errors.New("Commit " + commit.Hash + " is not reachable from the new head " + head + "!")
+		}
+	}
+
+	return dropped, nil
+}
+
 // Updates the name of an existing branch to the repo if it creates a new valid state
 // a new branch must have a new unique name and it must be consistent
 // and builds on the current repo state
 func (repo *Repository) UpdateBranchName(branch Branch, newName string) (bool, error) {
 	fmt.Println("Trying to update a branch ")
 
-	if repo.BranchExists(branch.Name) && branch.Name != "main" && newName != "main" {
+	if repo.BranchExists(branch.Name) && branch.Name != DefaultBranchName && newName != DefaultBranchName {
 		branch := repo.Branches[branch.Name]
 		if !repo.BranchExists(newName) {
 			fmt.Println("New branch is valid!")
@@ -320,7 +1096,7 @@ func (repo *Repository) UpdateBranchName(branch Branch, newName string) (bool, e
 
 // Delete a branch from the repo
 func (repo *Repository) DeleteBranch(name string) (bool, error) {
-	if repo.BranchExists(name) && name != "main" {
+	if repo.BranchExists(name) && name != DefaultBranchName {
 		delete(repo.Branches, name)
 		return true, nil
 	}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// returns a repo of alice holding the branches
+func testRepo(branches ...Branch) Repository {
+	branchMap := make(map[string]Branch)
+	for _, branch := range branches {
+		branchMap[branch.Name] = branch
+	}
+	repo, _ := CreateNewRepo("repo", "alice", "", branchMap, nil, testEpoch)
+	return repo
+}
+
+func TestRepositoryPushCommits(t *testing.T) {
+	a := testCommit("a", 0)
+	b := testCommit("b", 1, "a")
+	side := testCommit("side", 2, "a")
+
+	tests := []struct {
+		name         string
+		branch       string
+		expectedHead string
+		commits      []Commit
+		allowNewRoot bool
+		head         string // head of the branch after the push, empty if the push fails
+	}{
+		{"unknown branch", "missing", "", []Commit{testCommit("c", 2, "b")}, false, ""},
+		{"no commits", "main", "b", []Commit{}, false, ""},
+		{"stale expected head", "main", "a", []Commit{testCommit("c", 2, "b")}, false, ""},
+		{"fast-forward", "main", "b", []Commit{testCommit("c", 2, "b")}, false, "c"},
+		{"fast-forward without expected head", "main", "", []Commit{testCommit("c", 2, "b")}, false, "c"},
+		{"fast-forward by several commits", "main", "b", []Commit{testCommit("c", 2, "b"), testCommit("d", 3, "c")}, false, "d"},
+		{"several heads", "main", "b", []Commit{testCommit("c", 2, "b"), testCommit("d", 3, "b")}, false, ""},
+		{"merge of commits new to the repo", "main", "b", []Commit{testCommit("x", 2, "a"), testCommit("m", 3, "b", "x")}, false, "m"},
+		{"commits new to the repo left unmerged", "main", "b", []Commit{testCommit("c", 2, "b"), testCommit("x", 3, "a")}, false, ""},
+		{"rewrite of the history", "main", "b", []Commit{testCommit("c", 2, "a")}, false, ""},
+		{"merge of another branch", "main", "b", []Commit{testCommit("c", 3, "b", "side")}, false, "c"},
+		{"new root", "main", "b", []Commit{testCommit("root", 2)}, false, ""},
+		{"new root allowed replacing the history", "main", "b", []Commit{testCommit("root", 2)}, true, ""},
+		{"new root allowed merged into the history", "main", "b", []Commit{testCommit("root", 2), testCommit("m", 3, "b", "root")}, true, "m"},
+		{"new root allowed for an empty branch", "empty", "", []Commit{testCommit("root", 2)}, true, "root"},
+		{"first push to an empty branch", "empty", "", []Commit{testCommit("c", 2, "b")}, false, "c"},
+		{"dangling parent", "empty", "", []Commit{testCommit("c", 2, "missing")}, false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := testRepo(testBranch("main", a, b), testBranch("feature", a, side), testBranch("empty"))
+
+			err := repo.PushCommits(test.commits, test.branch, test.expectedHead, test.allowNewRoot)
+			if test.head == "" {
+				if err == nil {
+					t.Fatalf("PushCommits() accepted the push, head is now %s", repo.Branches[test.branch].Head)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PushCommits() = %v, want the head at %s", err, test.head)
+			}
+			if head := repo.Branches[test.branch].Head; head != test.head {
+				t.Fatalf("head = %s, want %s", head, test.head)
+			}
+		})
+	}
+}
+
+func TestRepositoryPushCommitsFromTheSameState(t *testing.T) {
+	repo := testRepo(testBranch("main", testCommit("a", 0), testCommit("b", 1, "a")))
+
+	if err := repo.PushCommits([]Commit{testCommit("c", 2, "b")}, "main", "b", false); err != nil {
+		t.Fatalf("first push: %v", err)
+	}
+	if err := repo.PushCommits([]Commit{testCommit("d", 2, "b")}, "main", "b", false); err == nil {
+		t.Fatalf("second push from the same head was accepted")
+	}
+	if head := repo.Branches["main"].Head; head != "c" {
+		t.Fatalf("head = %s, want c", head)
+	}
+}
+
+func TestRepositoryValidBranchCommits(t *testing.T) {
+	a := testCommit("a", 0)
+	b := testCommit("b", 1, "a")
+
+	tests := []struct {
+		name         string
+		repo         Repository
+		commits      []Commit
+		allowNewRoot bool
+		valid        bool
+	}{
+		{"commits of the repo only", testRepo(testBranch("main", a, b)), []Commit{a, b}, false, true},
+		{"new commits on the repo history", testRepo(testBranch("main", a, b)), []Commit{a, b, testCommit("d", 3, "c"), testCommit("c", 2, "b")}, false, true},
+		{"parent missing from the repo", testRepo(testBranch("main", a, b)), []Commit{testCommit("c", 2, "missing")}, false, false},
+		{"parent newer than its child", testRepo(testBranch("main", a, b)), []Commit{testCommit("c", 0, "b")}, false, false},
+		{"new root", testRepo(testBranch("main", a, b)), []Commit{testCommit("root", 2), testCommit("c", 3, "root")}, false, false},
+		{"new root allowed", testRepo(testBranch("main", a, b)), []Commit{testCommit("root", 2), testCommit("c", 3, "root")}, true, true},
+		{"root of an empty repo", testRepo(), []Commit{testCommit("root", 2), testCommit("c", 3, "root")}, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.repo.ValidBranchCommits(testBranch("feature", test.commits...), test.allowNewRoot)
+			if valid := err == nil; valid != test.valid {
+				t.Fatalf("ValidBranchCommits() = %v, want valid %v", err, test.valid)
+			}
+		})
+	}
+}
+
+func TestRepositoryAccessExpiry(t *testing.T) {
+	expiresAt := testEpoch.Add(time.Hour)
+	repo := testRepo()
+	if !repo.UpdateAccess("bob", WriteAccess, "alice", testEpoch, expiresAt) {
+		t.Fatalf("time-limited grant refused")
+	}
+
+	repo.CurrentTime = expiresAt.Add(-time.Second)
+	if access := repo.GetUserAccess("bob"); access != WriteAccess {
+		t.Fatalf("access before expiry = %v, want %v", access, WriteAccess)
+	}
+
+	repo.CurrentTime = expiresAt
+	if access := repo.GetUserAccess("bob"); access != NoAccess {
+		t.Fatalf("access at expiry = %v, want %v", access, NoAccess)
+	}
+
+	validities := GetAccessLogValidities(repo.AccessLogs, expiresAt.Add(-time.Minute))
+	if last := validities[len(validities)-1]; last.Expired || last.RemainingValidity != time.Minute.String() {
+		t.Fatalf("validity = %+v, want a minute left", last)
+	}
+
+	// an expiry must be in the future
+	if repo.UpdateAccess("carol", ReadAccess, "alice", testEpoch, testEpoch) {
+		t.Fatalf("grant expiring when it is made accepted")
+	}
+
+	// granting again without expiry makes the grant permanent
+	if !repo.UpdateAccess("bob", WriteAccess, "alice", testEpoch.Add(2*time.Hour), time.Time{}) {
+		t.Fatalf("permanent grant refused")
+	}
+	if access := repo.GetUserAccess("bob"); access != WriteAccess {
+		t.Fatalf("access after a permanent grant = %v, want %v", access, WriteAccess)
+	}
+}
+
+func TestRepositoryTransfer(t *testing.T) {
+	repo := testRepo()
+	repo.UpdateAccess("admin", AdminAccess, "alice", testEpoch, time.Time{})
+
+	if repo.Transfer("bob", "admin", testEpoch, ReadAccess) {
+		t.Fatalf("transfer by an admin accepted")
+	}
+	if repo.Transfer("bob", "alice", testEpoch, UserAccess(42)) {
+		t.Fatalf("transfer with an unknown access for the previous author accepted")
+	}
+
+	if !repo.Transfer("bob", "alice", testEpoch, ReadAccess) {
+		t.Fatalf("transfer by the owner refused")
+	}
+	if repo.Author != "bob" || !repo.IsOwner("bob") {
+		t.Fatalf("author = %s, want bob owning the repo", repo.Author)
+	}
+	if access := repo.GetUserAccess("alice"); access != ReadAccess {
+		t.Fatalf("access of the previous author = %v, want %v", access, ReadAccess)
+	}
+
+	// the owners of an organization lose the repo it transferred
+	organization, _ := CreateNewOrganization("acme", "olivia")
+	orgRepo, _ := CreateNewRepo("repo", "acme", "", nil, nil, testEpoch)
+	orgRepo.Organization = &organization
+	if !orgRepo.Transfer("bob", "olivia", testEpoch, NoAccess) {
+		t.Fatalf("transfer by an owner of the organization refused")
+	}
+	if owners := orgRepo.GetUsersWithAccess(OwnerAccess); len(owners) != 1 || owners[0] != "bob" {
+		t.Fatalf("owners = %v, want [bob]", owners)
+	}
+}
+
+func TestRepositoryUpdateAccessKeepsAnOwner(t *testing.T) {
+	repo := testRepo()
+
+	// an owner cannot change their own access, so the only owner cannot leave the repo without owners
+	if repo.UpdateAccess("alice", ReadAccess, "alice", testEpoch, time.Time{}) {
+		t.Fatalf("only owner downgraded themselves")
+	}
+
+	if repo.UpdateAccess("bob", OwnerAccess, "alice", testEpoch, testEpoch.Add(time.Hour)) {
+		t.Fatalf("expiring owner grant accepted")
+	}
+	if !repo.UpdateAccess("bob", OwnerAccess, "alice", testEpoch, time.Time{}) {
+		t.Fatalf("second owner refused")
+	}
+
+	// with two owners, one can revoke the other
+	if !repo.UpdateAccess("alice", NoAccess, "bob", testEpoch.Add(time.Minute), time.Time{}) {
+		t.Fatalf("revoking one of two owners refused")
+	}
+	if owners := repo.GetUsersWithAccess(OwnerAccess); len(owners) != 1 || owners[0] != "bob" {
+		t.Fatalf("owners = %v, want [bob]", owners)
+	}
+}
+
+func TestUnmarshalRepoIgnoresAccessLogs(t *testing.T) {
+	forged := `{"name": "repo", "author": "alice", "accessLogs": [
+		{"authorizer": "alice", "authorized": "alice", "userAccess": 3, "expiresAt": "2024-01-01T01:00:00Z"},
+		{"authorizer": "alice", "authorized": "mallory", "userAccess": 3},
+		{"authorizer": "alice", "authorized": "*", "userAccess": 4, "visibility": "public"}]}`
+
+	repo, err := UnmarshalRepo(forged, testEpoch)
+	if err != nil {
+		t.Fatalf("UnmarshalRepo() = %v", err)
+	}
+
+	if len(repo.AccessLogs) != 1 || repo.AccessLogs[0].Authorized != "alice" || !repo.AccessLogs[0].ExpiresAt.IsZero() {
+		t.Fatalf("access logs = %+v, want the non-expiring ownership of alice only", repo.AccessLogs)
+	}
+	if repo.GetUserAccess("mallory") != NoAccess || repo.Visibility != PrivateVisibility {
+		t.Fatalf("forged grants were applied")
+	}
+}
+
+func TestRepositoryPushCommitsNewRootByNonOwner(t *testing.T) {
+	repo := testRepo(testBranch("main", testCommit("a", 0), testCommit("b", 1, "a")))
+	repo.UpdateAccess("bob", MaintainAccess, "alice", testEpoch, time.Time{})
+
+	root := testCommit("root", 2)
+	if !repo.CanPush("bob", "main") || repo.CheckBranchPush("main", "bob", []Commit{root}, false) != nil {
+		t.Fatalf("bob cannot push to main")
+	}
+
+	// replacing the history is a force-push, which only owners can do
+	if err := repo.PushCommits([]Commit{root}, "main", "b", true); err == nil {
+		t.Fatalf("new root replaced the head of main")
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// This enum represents the verdict of a review
+type ReviewKind string
+
+const (
+	ReviewApprove        ReviewKind = "approve"
+	ReviewRequestChanges ReviewKind = "request-changes"
+	ReviewComment        ReviewKind = "comment"
+)
+
+// checks if the kind is one of the known verdicts
+func (kind ReviewKind) IsValid() bool {
+	return kind == ReviewApprove || kind == ReviewRequestChanges || kind == ReviewComment
+}
+
+// This structure is modeling the review of the head commit of the source branch of a pull request.
+// A review only applies to the commit it was made on, so it goes stale when the source branch moves.
+type Review struct {
+	ID              string     `json:"id"`
+	RepoAuthor      string     `json:"repoAuthor"`
+	RepoName        string     `json:"repoName"`
+	PullRequestID   string     `json:"pullRequestID"`
+	TargetBranch    string     `json:"targetBranch"`
+	Commit          string     `json:"commit"`
+	Reviewer        string     `json:"reviewer"`
+	Kind            ReviewKind `json:"kind"`
+	Body            string     `json:"body"`
+	Signature       string     `json:"signature,omitempty"`
+	SignatureFormat string     `json:"signatureFormat,omitempty"`
+	Verified        bool       `json:"verified"`
+	Timestamp       time.Time  `json:"timestamp"`
+	Stale           bool       `json:"stale"` // set when the review is queried, not stored
+}
+
+// helper function that creates a new review of the head of a pull request
+func CreateNewReview(id string, pullRequest PullRequest, reviewer string, kind ReviewKind, body string, timestamp time.Time) (Review, error) {
+	var review Review
+
+	review.ID = id
+	review.RepoAuthor = pullRequest.RepoAuthor
+	review.RepoName = pullRequest.RepoName
+	review.PullRequestID = pullRequest.ID
+	review.TargetBranch = pullRequest.TargetBranch
+	review.Commit = pullRequest.Head()
+	review.Reviewer = reviewer
+	review.Kind = kind
+	review.Body = body
+	review.Timestamp = timestamp
+
+	return review, nil
+}
+
+// returns the canonical serialization of the review that is covered by its signature.
+// It is the compact JSON object with sorted keys
+// {"body", "commit", "kind", "pullRequestID", "repoAuthor", "repoName", "targetBranch"}.
+func (review *Review) SigningPayload() []byte {
+	data := map[string]interface{}{"body": review.Body, "commit": review.Commit, "kind": review.Kind, "pullRequestID": review.PullRequestID,
+		"repoAuthor": review.RepoAuthor, "repoName": review.RepoName, "targetBranch": review.TargetBranch}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(data)
+
+	return bytes.TrimRight(buffer.Bytes(), "\n")
+}
+
+// verifies the review signature against the reviewer's keys that are valid at the review's timestamp.
+// Unsigned reviews are left unverified without error.
+func (review *Review) VerifySignature(keys []UserKey) error {
+	review.Verified = false
+
+	if review.Signature == "" {
+		return nil
+	}
+
+	validKeys, err := UserKeysValidAt(keys, review.Timestamp)
+	if err != nil {
+		return errors.New("Signature of the review is not valid: " + err.Error())
+	}
+
+	_, err = VerifySignatureWithAnyKey(validKeys, review.SigningPayload(), review.Signature, review.SignatureFormat)
+	if err != nil {
+		return errors.New("Signature of the review is not valid: " + err.Error())
+	}
+
+	review.Verified = true
+	return nil
+}
+
+// returns the reviewers whose latest verdict on the current head of the pull request approves it,
+// and those whose latest verdict requests changes.
+// Only verdicts of maintainers or higher other than the author of the pull request count.
+func (repo *Repository) GetReviewDecisions(pullRequest *PullRequest, reviews []Review) ([]string, []string) {
+	sort.Slice(reviews, func(i, j int) bool {
+		return reviews[i].Timestamp.Before(reviews[j].Timestamp)
+	})
+
+	verdicts := make(map[string]ReviewKind)
+	for _, review := range reviews {
+		if review.Commit != pullRequest.Head() || review.Kind == ReviewComment || review.Reviewer == pullRequest.Author {
+			continue
+		}
+		if repo.GetUserAccess(review.Reviewer).Level() < ReadWriteAccess.Level() {
+			continue
+		}
+		verdicts[review.Reviewer] = review.Kind
+	}
+
+	approvers := make([]string, 0)
+	changesRequestedBy := make([]string, 0)
+	for reviewer, kind := range verdicts {
+		if kind == ReviewApprove {
+			approvers = append(approvers, reviewer)
+		} else {
+			changesRequestedBy = append(changesRequestedBy, reviewer)
+		}
+	}
+	sort.Strings(approvers)
+	sort.Strings(changesRequestedBy)
+
+	return approvers, changesRequestedBy
+}
+
+// checks that a pull request has enough approvals on its current head, and no requested changes,
+// to be merged into its target branch. Only merges into protected branches need approvals.
+func (repo *Repository) CheckPullRequestApprovals(pullRequest *PullRequest, reviews []Review) error {
+	if repo.RequiredApprovals == 0 || len(repo.GetBranchProtections(pullRequest.TargetBranch)) == 0 {
+		return nil
+	}
+
+	approvers, changesRequestedBy := repo.GetReviewDecisions(pullRequest, reviews)
+	if len(changesRequestedBy) > 0 {
+		return errors.New("Changes to pull request " + pullRequest.ID + " have been requested by " + changesRequestedBy[0])
+	}
+
+	if len(approvers) < repo.RequiredApprovals {
+		return errors.New("Pull request " + pullRequest.ID + " has " + strconv.Itoa(len(approvers)) + " of the " +
+			strconv.Itoa(repo.RequiredApprovals) + " approvals required to merge into " + pullRequest.TargetBranch)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRepositoryGetReviewDecisions(t *testing.T) {
+	repo := testRepo(testBranch("main", testCommit("a", 0)))
+	grants := map[string]UserAccess{"bob": MaintainAccess, "frank": MaintainAccess, "admin": AdminAccess, "writer": WriteAccess,
+		"reader": ReadAccess, "erin": MaintainAccess}
+	for user, access := range grants {
+		if !repo.UpdateAccess(user, access, "alice", testEpoch, time.Time{}) {
+			t.Fatalf("could not grant %s access", user)
+		}
+	}
+	if !repo.UpdateAccess("gina", MaintainAccess, "alice", testEpoch, testEpoch.Add(time.Hour)) {
+		t.Fatalf("could not grant gina access")
+	}
+	repo.CurrentTime = testEpoch.Add(2 * time.Hour)
+
+	pullRequest, _ := CreateNewPullRequest("1", "alice", "repo", "main", "alice", "repo", "feature", "erin", "title", "", "h1", testEpoch)
+	pullRequest.RecordHead("h2", testEpoch.Add(10*time.Minute))
+	review := func(reviewer string, kind ReviewKind, minute int) Review {
+		review, _ := CreateNewReview("", pullRequest, reviewer, kind, "", testEpoch.Add(time.Duration(minute)*time.Minute))
+		return review
+	}
+	// reviews made before the source branch moved to h2
+	staleReview := func(reviewer string, kind ReviewKind, minute int) Review {
+		review := review(reviewer, kind, minute)
+		review.Commit = "h1"
+		return review
+	}
+
+	tests := []struct {
+		name               string
+		reviews            []Review
+		approvers          []string
+		changesRequestedBy []string
+	}{
+		{"no reviews", []Review{}, []string{}, []string{}},
+		{"approval", []Review{review("bob", ReviewApprove, 11)}, []string{"bob"}, []string{}},
+		{"approval of a previous head", []Review{staleReview("bob", ReviewApprove, 5)}, []string{}, []string{}},
+		{"approval by the owner", []Review{review("alice", ReviewApprove, 11)}, []string{"alice"}, []string{}},
+		{"approval by an admin", []Review{review("admin", ReviewApprove, 11)}, []string{"admin"}, []string{}},
+		{"approval by a writer", []Review{review("writer", ReviewApprove, 11)}, []string{}, []string{}},
+		{"approval by a reader", []Review{review("reader", ReviewApprove, 11)}, []string{}, []string{}},
+		{"approval by a user without access", []Review{review("mallory", ReviewApprove, 11)}, []string{}, []string{}},
+		{"approval by an expired maintainer", []Review{review("gina", ReviewApprove, 11)}, []string{}, []string{}},
+		{"approval by the author", []Review{review("erin", ReviewApprove, 11)}, []string{}, []string{}},
+		{"comment", []Review{review("bob", ReviewComment, 11)}, []string{}, []string{}},
+		{"changes requested", []Review{review("bob", ReviewRequestChanges, 11)}, []string{}, []string{"bob"}},
+		{"changes requested on a previous head", []Review{staleReview("bob", ReviewRequestChanges, 5)}, []string{}, []string{}},
+		{"changes requested after an approval", []Review{review("bob", ReviewApprove, 11), review("bob", ReviewRequestChanges, 12)},
+			[]string{}, []string{"bob"}},
+		{"approval after requesting changes", []Review{review("bob", ReviewApprove, 12), review("bob", ReviewRequestChanges, 11)},
+			[]string{"bob"}, []string{}},
+		{"comment after an approval", []Review{review("bob", ReviewApprove, 11), review("bob", ReviewComment, 12)},
+			[]string{"bob"}, []string{}},
+		{"approval again after a push", []Review{staleReview("bob", ReviewApprove, 5), review("frank", ReviewApprove, 11)},
+			[]string{"frank"}, []string{}},
+		{"several reviewers", []Review{review("frank", ReviewApprove, 11), review("bob", ReviewApprove, 12), review("admin", ReviewRequestChanges, 13)},
+			[]string{"bob", "frank"}, []string{"admin"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			approvers, changesRequestedBy := repo.GetReviewDecisions(&pullRequest, test.reviews)
+			if !slices.Equal(approvers, test.approvers) {
+				t.Fatalf("approvers = %v, want %v", approvers, test.approvers)
+			}
+			if !slices.Equal(changesRequestedBy, test.changesRequestedBy) {
+				t.Fatalf("changes requested by %v, want %v", changesRequestedBy, test.changesRequestedBy)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+)
+
+// Supported signature schemes. An empty format lets the scheme be derived from the key type.
+const (
+	SignatureFormatAuto        = ""
+	SignatureFormatRSAPKCS1v15 = "rsa-pkcs1v15-sha256"
+	SignatureFormatRSAPSS      = "rsa-pss-sha256"
+	SignatureFormatECDSA       = "ecdsa-sha256"
+	SignatureFormatEd25519     = "ed25519"
+)
+
+// parses a PEM encoded public key as registered by users.
+// Supported key types are RSA, ECDSA and Ed25519.
+func ParsePublicKey(publicKeyPEM string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, errors.New("Public key is not PEM encoded!")
+	}
+
+	if publicKey, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return publicKey, nil
+	}
+
+	if publicKey, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return publicKey, nil
+	}
+
+	return nil, errors.New("Public key format is not supported!")
+}
+
+// checks that signatureB64 is a valid base64 encoded signature of message made with
+// the private key matching publicKeyPEM, deriving the signature scheme from the key type.
+func VerifySignature(publicKeyPEM string, message []byte, signatureB64 string) error {
+	return VerifySignatureWithFormat(publicKeyPEM, message, signatureB64, SignatureFormatAuto)
+}
+
+// checks that signatureB64 is a valid base64 encoded signature of message made with
+// the private key matching publicKeyPEM using the requested signature format.
+// RSA signatures may use PKCS#1 v1.5 or PSS over SHA-256, ECDSA signatures are ASN.1 over SHA-256
+// and Ed25519 signatures are over the raw message.
+func VerifySignatureWithFormat(publicKeyPEM string, message []byte, signatureB64 string, format string) error {
+	publicKey, err := ParsePublicKey(publicKeyPEM)
+	if err != nil {
+		return err
+	}
+
+	signature, err := b64.StdEncoding.DecodeString(signatureB64)
+	if err != nil {
+		return errors.New("Signature is not base64 encoded!")
+	}
+
+	digest := sha256.Sum256(message)
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if format == SignatureFormatAuto || format == SignatureFormatRSAPKCS1v15 {
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return nil
+			}
+		}
+		if format == SignatureFormatAuto || format == SignatureFormatRSAPSS {
+			if rsa.VerifyPSS(key, crypto.SHA256, digest[:], signature, nil) == nil {
+				return nil
+			}
+		}
+	case *ecdsa.PublicKey:
+		if format == SignatureFormatAuto || format == SignatureFormatECDSA {
+			if ecdsa.VerifyASN1(key, digest[:], signature) {
+				return nil
+			}
+		}
+	case ed25519.PublicKey:
+		if format == SignatureFormatAuto || format == SignatureFormatEd25519 {
+			if ed25519.Verify(key, message, signature) {
+				return nil
+			}
+		}
+	default:
+		return errors.New("Public key type is not supported!")
+	}
+
+	return errors.New("Signature verification failed!")
+}
+
+// returns the hex encoded SHA-256 fingerprint of the DER encoding of a PEM encoded public key
+func PublicKeyFingerprint(publicKeyPEM string) (string, error) {
+	publicKey, err := ParsePublicKey(publicKeyPEM)
+	if err != nil {
+		return "", err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", errors.New("Public key could not be encoded!")
+	}
+
+	fingerprint := sha256.Sum256(der)
+	return hex.EncodeToString(fingerprint[:]), nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	b64 "encoding/base64"
+	"encoding/pem"
+	"testing"
+)
+
+// A key pair of one of the supported key types, signing with its default scheme
+type testSigner struct {
+	publicKeyPEM string
+	sign         func(message []byte) string
+}
+
+func newRSATestSigner(t *testing.T) testSigner {
+	t.Helper()
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSigner{encodeTestPublicKey(t, &privateKey.PublicKey), func(message []byte) string {
+		digest := sha256.Sum256(message)
+		signature, _ := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+		return b64.StdEncoding.EncodeToString(signature)
+	}}
+}
+
+func newECDSATestSigner(t *testing.T) testSigner {
+	t.Helper()
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSigner{encodeTestPublicKey(t, &privateKey.PublicKey), func(message []byte) string {
+		digest := sha256.Sum256(message)
+		signature, _ := ecdsa.SignASN1(rand.Reader, privateKey, digest[:])
+		return b64.StdEncoding.EncodeToString(signature)
+	}}
+}
+
+func newEd25519TestSigner(t *testing.T) testSigner {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSigner{encodeTestPublicKey(t, publicKey), func(message []byte) string {
+		return b64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, message))
+	}}
+}
+
+// returns the PKIX PEM encoding of a public key, as registered by users
+func encodeTestPublicKey(t *testing.T, publicKey crypto.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestVerifySignature(t *testing.T) {
+	message := []byte("nonce")
+	signers := map[string]testSigner{
+		"rsa":     newRSATestSigner(t),
+		"ecdsa":   newECDSATestSigner(t),
+		"ed25519": newEd25519TestSigner(t),
+	}
+
+	for keyType, signer := range signers {
+		t.Run(keyType, func(t *testing.T) {
+			signature := signer.sign(message)
+			if err := VerifySignature(signer.publicKeyPEM, message, signature); err != nil {
+				t.Fatalf("valid signature rejected: %v", err)
+			}
+			if err := VerifySignature(signer.publicKeyPEM, []byte("other nonce"), signature); err == nil {
+				t.Fatalf("signature of another message accepted")
+			}
+			if err := VerifySignature(signer.publicKeyPEM, message, "not base64!"); err == nil {
+				t.Fatalf("malformed signature accepted")
+			}
+		})
+	}
+
+	// a signature only verifies against the key that made it
+	if err := VerifySignature(signers["ecdsa"].publicKeyPEM, message, newECDSATestSigner(t).sign(message)); err == nil {
+		t.Fatalf("signature made with another key accepted")
+	}
+}
+
+func TestVerifySignatureWithFormat(t *testing.T) {
+	message := []byte("nonce")
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyPEM := encodeTestPublicKey(t, &privateKey.PublicKey)
+
+	digest := sha256.Sum256(message)
+	pss, _ := rsa.SignPSS(rand.Reader, privateKey, crypto.SHA256, digest[:], nil)
+	pssSignature := b64.StdEncoding.EncodeToString(pss)
+
+	if err := VerifySignatureWithFormat(publicKeyPEM, message, pssSignature, SignatureFormatRSAPSS); err != nil {
+		t.Fatalf("PSS signature rejected: %v", err)
+	}
+	if err := VerifySignatureWithFormat(publicKeyPEM, message, pssSignature, SignatureFormatAuto); err != nil {
+		t.Fatalf("PSS signature rejected without a format: %v", err)
+	}
+	if err := VerifySignatureWithFormat(publicKeyPEM, message, pssSignature, SignatureFormatRSAPKCS1v15); err == nil {
+		t.Fatalf("PSS signature accepted as PKCS#1 v1.5")
+	}
+
+	// the format must match the key type
+	ed25519Signer := newEd25519TestSigner(t)
+	if err := VerifySignatureWithFormat(ed25519Signer.publicKeyPEM, message, ed25519Signer.sign(message), SignatureFormatECDSA); err == nil {
+		t.Fatalf("Ed25519 signature accepted as ECDSA")
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs1 := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&privateKey.PublicKey)}))
+	pkix := encodeTestPublicKey(t, &privateKey.PublicKey)
+
+	if _, err := ParsePublicKey(pkcs1); err != nil {
+		t.Fatalf("PKCS#1 key rejected: %v", err)
+	}
+	if _, err := ParsePublicKey("not a key"); err == nil {
+		t.Fatalf("key that is not PEM encoded accepted")
+	}
+
+	// both encodings of a key share its fingerprint
+	pkcs1Fingerprint, _ := PublicKeyFingerprint(pkcs1)
+	pkixFingerprint, _ := PublicKeyFingerprint(pkix)
+	if pkcs1Fingerprint == "" || pkcs1Fingerprint != pkixFingerprint {
+		t.Fatalf("fingerprints = %q and %q, want the same fingerprint", pkcs1Fingerprint, pkixFingerprint)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// This structure is modeling a named reference to a commit of a repository, such as a release.
+// Lightweight tags only name the commit, annotated tags also carry a tagger and a message,
+// optionally signed by the tagger.
+type Tag struct {
+	Name            string    `json:"name"`
+	RepoAuthor      string    `json:"repoAuthor"`
+	RepoName        string    `json:"repoName"`
+	Commit          string    `json:"commit"`
+	Tagger          string    `json:"tagger,omitempty"`
+	Message         string    `json:"message,omitempty"`
+	Signature       string    `json:"signature,omitempty"`
+	SignatureFormat string    `json:"signatureFormat,omitempty"`
+	Verified        bool      `json:"verified"`
+	CreatedBy       string    `json:"createdBy"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// helper function that creates a new lightweight tag
+func CreateNewTag(name string, repoAuthor string, repoName string, commit string, createdBy string, timestamp time.Time) (Tag, error) {
+	var tag Tag
+
+	if err := CheckTagName(name); err != nil {
+		return tag, err
+	}
+
+	tag.Name = name
+	tag.RepoAuthor = repoAuthor
+	tag.RepoName = repoName
+	tag.Commit = commit
+	tag.CreatedBy = createdBy
+	tag.Timestamp = timestamp
+
+	return tag, nil
+}
+
+// checks that a tag name is a valid git reference name, so that it can be part of composite keys
+// and matched by tag protection patterns: valid UTF-8 made of slash separated components that do not start with a dot
+// or end with ".lock", without "..", "@{", control characters, spaces or any of ~^:?*[\
+func CheckTagName(name string) error {
+	invalid := errors.New("Invalid tag name " + strconv.Quote(name) + "!")
+
+	if !utf8.ValidString(name) || name == "" || name == "@" || strings.HasSuffix(name, ".") || strings.Contains(name, "..") || strings.Contains(name, "@{") {
+		return invalid
+	}
+
+	for _, char := range name {
+		if char < 0x20 || char == 0x7f || strings.ContainsRune(" ~^:?*[\\", char) {
+			return invalid
+		}
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		if component == "" || strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return invalid
+		}
+	}
+
+	return nil
+}
+
+// checks if the tag carries a tagger and a message
+func (tag *Tag) IsAnnotated() bool {
+	return tag.Tagger != ""
+}
+
+// returns the canonical serialization of an annotated tag that is covered by its signature.
+// It is the compact JSON object with sorted keys
+// {"commit", "message", "name", "repoAuthor", "repoName", "tagger"}.
+func (tag *Tag) SigningPayload() []byte {
+	data := map[string]interface{}{"commit": tag.Commit, "message": tag.Message, "name": tag.Name,
+		"repoAuthor": tag.RepoAuthor, "repoName": tag.RepoName, "tagger": tag.Tagger}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(data)
+
+	return bytes.TrimRight(buffer.Bytes(), "\n")
+}
+
+// verifies the tag signature against the tagger's keys that are valid at the tag's timestamp.
+// Unsigned tags are left unverified without error.
+func (tag *Tag) VerifySignature(keys []UserKey) error {
+	tag.Verified = false
+
+	if tag.Signature == "" {
+		return nil
+	}
+
+	validKeys, err := UserKeysValidAt(keys, tag.Timestamp)
+	if err != nil {
+		return errors.New("Signature of tag " + tag.Name + " is not valid: " + err.Error())
+	}
+
+	_, err = VerifySignatureWithAnyKey(validKeys, tag.SigningPayload(), tag.Signature, tag.SignatureFormat)
+	if err != nil {
+		return errors.New("Signature of tag " + tag.Name + " is not valid: " + err.Error())
+	}
+
+	tag.Verified = true
+	return nil
+}
+
+// sorts tags by name
+func SortTags(tags []Tag) {
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+}
+
+// This structure is modeling a protection rule for the tags of a repository
+// whose name matches Pattern, either an exact tag name or a glob such as "v*".
+type TagProtectionRule struct {
+	Pattern    string `json:"pattern"`
+	NoMove     bool   `json:"noMove"`
+	NoDeletion bool   `json:"noDeletion"`
+	OwnerOnly  bool   `json:"ownerOnly"` // only owners can create, move or delete matching tags
+}
+
+// This function takes a json string that represents the marshalling of TagProtectionRule
+// and returns a TagProtectionRule.
+func UnmarshalTagProtectionRule(objectString string) (TagProtectionRule, error) {
+	var rule TagProtectionRule
+
+	err := json.Unmarshal([]byte(objectString), &rule)
+	if err != nil {
+		return rule, err
+	}
+
+	if _, err := path.Match(rule.Pattern, ""); err != nil || rule.Pattern == "" {
+		return rule, errors.New("Invalid tag pattern " + rule.Pattern + "!")
+	}
+
+	return rule, nil
+}
+
+// checks if the rule applies to the mentioned tag
+func (rule *TagProtectionRule) Matches(tagName string) bool {
+	if rule.Pattern == tagName {
+		return true
+	}
+	matched, _ := path.Match(rule.Pattern, tagName)
+	return matched
+}
+
+// returns an error naming the rule and the option that rejected an operation
+func (rule *TagProtectionRule) violation(tagName string, option string) error {
+	return errors.New("Tag " + tagName + " is protected by rule '" + rule.Pattern + "': " + option)
+}
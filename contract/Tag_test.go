@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTagProtectionRuleMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		tagName string
+		matches bool
+	}{
+		{"v1.0.0", "v1.0.0", true},
+		{"v1.0.0", "v1.0.1", false},
+		{"v*", "v1.0.0", true},
+		{"v*", "release-1", false},
+		{"v*", "v", true},
+		{"v1.?", "v1.2", true},
+		{"v1.?", "v1.10", false},
+		{"v[0-9]*", "v2", true},
+		{"v[0-9]*", "vx", false},
+		{"release/*", "release/1.0", true},
+		{"release/*", "release/1.0/hotfix", false},
+		{"*", "release/1.0", false},
+		{"*", "v1.0.0", true},
+		{"[", "[", true},
+		{"[", "v1", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.tagName, func(t *testing.T) {
+			rule := TagProtectionRule{Pattern: test.pattern}
+			if matches := rule.Matches(test.tagName); matches != test.matches {
+				t.Fatalf("Matches(%q) = %v, want %v", test.tagName, matches, test.matches)
+			}
+		})
+	}
+}
+
+func TestCheckTagName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"v1.0.0", true},
+		{"release/1.0", true},
+		{"release-candidate_1", true},
+		{"vé", true},
+		{"", false},
+		{"@", false},
+		{"v1.", false},
+		{"v1..0", false},
+		{"v@{1}", false},
+		{"v 1", false},
+		{"v1\t", false},
+		{"v1\x7f", false},
+		{"v~1", false},
+		{"v^1", false},
+		{"v:1", false},
+		{"v?1", false},
+		{"v*", false},
+		{"v[1]", false},
+		{`v\1`, false},
+		{"/v1", false},
+		{"v1/", false},
+		{"release//1.0", false},
+		{".v1", false},
+		{"release/.1", false},
+		{"v1.lock", false},
+		{"v1.lock/v2", false},
+		{"v\xff", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := CheckTagName(test.name); (err == nil) != test.valid {
+				t.Fatalf("CheckTagName(%q) = %v, want valid %v", test.name, err, test.valid)
+			}
+		})
+	}
+}
+
+func TestRepositoryCanProtectTag(t *testing.T) {
+	repo := testRepo()
+	repo.UpdateAccess("admin", AdminAccess, "alice", testEpoch, time.Time{})
+	repo.SetTagProtection(TagProtectionRule{Pattern: "v*", OwnerOnly: true})
+	repo.SetTagProtection(TagProtectionRule{Pattern: "nightly", NoDeletion: true})
+
+	if !repo.CanProtectTag("admin", TagProtectionRule{Pattern: "nightly", NoMove: true}) {
+		t.Fatalf("admin cannot change a rule that is not owner-only")
+	}
+	if repo.CanProtectTag("admin", TagProtectionRule{Pattern: "release-*", OwnerOnly: true}) {
+		t.Fatalf("admin can add an owner-only rule")
+	}
+	// removing the rule or dropping its flag both relax the protection of the matching tags
+	if repo.CanProtectTag("admin", repo.TagProtections["v*"]) || repo.CanProtectTag("admin", TagProtectionRule{Pattern: "v*"}) {
+		t.Fatalf("admin can remove or relax an owner-only rule")
+	}
+	if !repo.CanProtectTag("alice", repo.TagProtections["v*"]) {
+		t.Fatalf("owner cannot remove an owner-only rule")
+	}
+}
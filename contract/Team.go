@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+)
+
+// Repository access can be granted to a team through a principal of the form "@organization/team".
+// User names cannot start with this prefix.
+const TeamPrincipalPrefix = "@"
+
+// This structure is modeling an organization, which groups teams under common owners.
+type Organization struct {
+	Name   string          `json:"name"`
+	Owners map[string]bool `json:"owners"`
+}
+
+// This structure is modeling a team of users that can be granted access to repositories as a whole.
+type Team struct {
+	Organization string          `json:"organization"`
+	Name         string          `json:"name"`
+	Members      map[string]bool `json:"members"`
+}
+
+// helper function that creates a new organization owned by owner
+func CreateNewOrganization(name string, owner string) (Organization, error) {
+	var organization Organization
+
+	organization.Name = name
+	organization.Owners = make(map[string]bool)
+	organization.Owners[owner] = true
+
+	return organization, nil
+}
+
+// checks if the mentioned user is an owner of the organization and can thus manage its teams
+func (organization *Organization) IsOwner(user string) bool {
+	_, exist := organization.Owners[user]
+	return exist
+}
+
+// helper function that creates a new team without members
+func CreateNewTeam(organization string, name string) (Team, error) {
+	var team Team
+
+	team.Organization = organization
+	team.Name = name
+	team.Members = make(map[string]bool)
+
+	return team, nil
+}
+
+// returns the principal under which repository access is granted to the team
+func (team *Team) Principal() string {
+	return TeamPrincipal(team.Organization, team.Name)
+}
+
+// checks if the mentioned user is a member of the team
+func (team *Team) HasMember(user string) bool {
+	_, exist := team.Members[user]
+	return exist
+}
+
+// adds a user to the team, returns false if the user already is a member
+func (team *Team) AddMember(user string) bool {
+	if team.HasMember(user) {
+		return false
+	}
+	team.Members[user] = true
+	return true
+}
+
+// removes a user from the team, returns false if the user is not a member
+func (team *Team) RemoveMember(user string) bool {
+	if !team.HasMember(user) {
+		return false
+	}
+	delete(team.Members, user)
+	return true
+}
+
+// returns the principal under which repository access is granted to a team
+func TeamPrincipal(organization string, name string) string {
+	return TeamPrincipalPrefix + organization + "/" + name
+}
+
+// checks if an access principal is a team and returns its organization and name
+func ParseTeamPrincipal(principal string) (string, string, bool) {
+	if !strings.HasPrefix(principal, TeamPrincipalPrefix) {
+		return "", "", false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(principal, TeamPrincipalPrefix), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
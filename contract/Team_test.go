@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRepositoryGetUserAccessThroughTeams(t *testing.T) {
+	readers, _ := CreateNewTeam("acme", "readers")
+	readers.AddMember("bob")
+	readers.AddMember("carol")
+	maintainers, _ := CreateNewTeam("acme", "maintainers")
+	maintainers.AddMember("carol")
+	organization, _ := CreateNewOrganization("acme", "olivia")
+
+	repo := testRepo()
+	repo.Organization = &organization
+	repo.AddTeam(readers)
+	repo.AddTeam(maintainers)
+	repo.UpdateAccess(readers.Principal(), ReadAccess, "alice", testEpoch, time.Time{})
+	repo.UpdateAccess(maintainers.Principal(), ReadWriteAccess, "alice", testEpoch, time.Time{})
+	repo.UpdateAccess("bob", WriteAccess, "alice", testEpoch, time.Time{})
+
+	tests := []struct {
+		user   string
+		access UserAccess
+	}{
+		{"bob", WriteAccess},       // the direct grant is higher than the grant of the team
+		{"carol", ReadWriteAccess}, // the highest grant of the teams
+		{"olivia", OwnerAccess},    // owners of the organization own its repos
+		{"dave", NoAccess},         // member of no team
+	}
+
+	for _, test := range tests {
+		if access := repo.GetUserAccess(test.user); access != test.access {
+			t.Errorf("GetUserAccess(%s) = %v, want %v", test.user, access, test.access)
+		}
+	}
+
+	// removing a member takes the access of the team away
+	readers.RemoveMember("carol")
+	maintainers.RemoveMember("carol")
+	repo.AddTeam(readers)
+	repo.AddTeam(maintainers)
+	if access := repo.GetUserAccess("carol"); access != NoAccess {
+		t.Errorf("GetUserAccess(carol) = %v after leaving the teams, want %v", access, NoAccess)
+	}
+}
+
+func TestParseTeamPrincipal(t *testing.T) {
+	if organization, name, isTeam := ParseTeamPrincipal(TeamPrincipal("acme", "core")); !isTeam || organization != "acme" || name != "core" {
+		t.Fatalf("ParseTeamPrincipal() = %s, %s, %v, want acme, core, true", organization, name, isTeam)
+	}
+
+	for _, principal := range []string{"alice", "@acme", "@acme/", "@/core"} {
+		if _, _, isTeam := ParseTeamPrincipal(principal); isTeam {
+			t.Errorf("ParseTeamPrincipal(%s) parsed a team", principal)
+		}
+	}
+}
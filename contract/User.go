@@ -1,11 +1,169 @@
 package main
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+)
+
+// The email is only filled in for the user themselves, from the collection of personal data, and is empty once the user's data has been erased.
 type UserPublicInfo struct {
 	Name      string `json:"name"`
 	Email     string `json:"email"`
 	PublicKey string `json:"publicKey"`
+	EmailHash string `json:"emailHash,omitempty"`
 }
 
 type User struct {
 	PublicInfo UserPublicInfo `json:"userPublicInfo"`
 }
+
+// A public key registered by a user. A user may hold several active keys at once, one per device.
+// Keys are never deleted so that signatures made with a key stay verifiable after it has been replaced or revoked.
+type UserKey struct {
+	Name             string    `json:"name"`
+	Label            string    `json:"label"` // e.g. the device or CI runner holding the private key
+	PublicKey        string    `json:"publicKey"`
+	Fingerprint      string    `json:"fingerprint"`
+	ValidFrom        time.Time `json:"validFrom"`
+	ValidUntil       time.Time `json:"validUntil"` // zero while the key is still valid
+	RevocationReason string    `json:"revocationReason,omitempty"`
+}
+
+// The label given to the key a user registers with
+const DefaultUserKeyLabel = "default"
+
+// helper function to initialize a new key that becomes valid at validFrom
+func CreateNewUserKey(name string, label string, publicKey string, validFrom time.Time) (UserKey, error) {
+	var key UserKey
+
+	fingerprint, err := PublicKeyFingerprint(publicKey)
+	if err != nil {
+		return key, err
+	}
+
+	key.Name = name
+	key.Label = label
+	key.PublicKey = publicKey
+	key.Fingerprint = fingerprint
+	key.ValidFrom = validFrom
+
+	return key, nil
+}
+
+// checks if the key has been revoked
+func (key *UserKey) IsRevoked() bool {
+	return !key.ValidUntil.IsZero()
+}
+
+// checks if the key was valid at the provided time
+func (key *UserKey) ValidAt(t time.Time) bool {
+	if t.Before(key.ValidFrom) {
+		return false
+	}
+	return !key.IsRevoked() || t.Before(key.ValidUntil)
+}
+
+// revokes the key starting at the provided time
+func (key *UserKey) Revoke(t time.Time, reason string) {
+	key.ValidUntil = t
+	key.RevocationReason = reason
+}
+
+// sorts keys from the oldest to the newest
+func SortUserKeys(keys []UserKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ValidFrom.UnixNano() < keys[j].ValidFrom.UnixNano()
+	})
+}
+
+// returns the keys out of keys that were valid at the provided time
+func UserKeysValidAt(keys []UserKey, t time.Time) ([]UserKey, error) {
+	validKeys := make([]UserKey, 0)
+	for _, key := range keys {
+		if key.ValidAt(t) {
+			validKeys = append(validKeys, key)
+		}
+	}
+
+	if len(validKeys) == 0 {
+		return validKeys, errors.New("No key was valid at " + t.Format(time.RFC3339) + "!")
+	}
+
+	return validKeys, nil
+}
+
+// returns the keys out of keys that have not been revoked
+func ActiveUserKeys(keys []UserKey) []UserKey {
+	activeKeys := make([]UserKey, 0)
+	for _, key := range keys {
+		if !key.IsRevoked() {
+			activeKeys = append(activeKeys, key)
+		}
+	}
+	return activeKeys
+}
+
+// checks that signatureB64 is a signature of message made with any of keys
+func VerifySignatureWithAnyKey(keys []UserKey, message []byte, signatureB64 string, format string) (UserKey, error) {
+	err := errors.New("No key to verify the signature with!")
+	for _, key := range keys {
+		err = VerifySignatureWithFormat(key.PublicKey, message, signatureB64, format)
+		if err == nil {
+			return key, nil
+		}
+	}
+
+	var key UserKey
+	return key, err
+}
+
+// The Fabric client identity (MSP + certificate subject/issuer) that submits transactions.
+// Each identity can be logged in as at most one registered user at a time.
+type UserIdentity struct {
+	MSPID string `json:"mspID"`
+	ID    string `json:"id"`
+}
+
+// A one-time nonce issued by requestLoginChallenge. The user proves possession of
+// their private key by signing the nonce; a challenge can only be used once.
+type LoginChallenge struct {
+	ChallengeID string       `json:"challengeID"`
+	Name        string       `json:"name"`
+	Nonce       string       `json:"nonce"`
+	Identity    UserIdentity `json:"identity"`
+	Timestamp   time.Time    `json:"timestamp"`
+	Used        bool         `json:"used"`
+}
+
+// Login challenges are only valid for a short amount of time after being issued
+const LoginChallengeValidity = 5 * time.Minute
+
+// creates a new login challenge whose nonce is derived from the issuing transaction
+func CreateNewLoginChallenge(txID string, name string, identity UserIdentity, timestamp time.Time) (LoginChallenge, error) {
+	var challenge LoginChallenge
+
+	nonce := sha256.Sum256([]byte(txID + "\n" + name + "\n" + timestamp.Format(time.RFC3339Nano)))
+
+	challenge.ChallengeID = txID
+	challenge.Name = name
+	challenge.Nonce = hex.EncodeToString(nonce[:])
+	challenge.Identity = identity
+	challenge.Timestamp = timestamp
+	challenge.Used = false
+
+	return challenge, nil
+}
+
+// checks that the challenge can still be used to log in at the provided time
+func (challenge *LoginChallenge) IsValid(identity UserIdentity, currentTime time.Time) bool {
+	if challenge.Used {
+		return false
+	}
+	if challenge.Identity != identity {
+		return false
+	}
+	return !currentTime.After(challenge.Timestamp.Add(LoginChallengeValidity))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// The private data collection holding the personal data of users. Its entries can be purged,
+// leaving only their hashes on the ledger.
+const UserPIICollection = "collectionUserPII"
+
+// This structure is modeling the personal data of a user, which is never stored in the world state.
+// The salt keeps the hash of the email published in the user document from being matched against known emails.
+type UserPII struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Salt  string `json:"salt"`
+}
+
+// helper function to initialize the personal data of a user
+func CreateNewUserPII(name string, email string, salt string) (UserPII, error) {
+	var pii UserPII
+
+	pii.Name = name
+	pii.Email = email
+	pii.Salt = salt
+
+	return pii, nil
+}
+
+// returns the salted hash of the email, as hex
+func (pii *UserPII) EmailHash() string {
+	hash := sha256.Sum256([]byte(pii.Salt + "\n" + pii.Email))
+	return hex.EncodeToString(hash[:])
+}
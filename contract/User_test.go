@@ -0,0 +1,136 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginChallengeIsValid(t *testing.T) {
+	identity := UserIdentity{MSPID: "Org1MSP", ID: "x509::CN=alice"}
+	challenge, _ := CreateNewLoginChallenge("tx1", "alice", identity, testEpoch)
+
+	tests := []struct {
+		name     string
+		identity UserIdentity
+		at       time.Time
+		used     bool
+		valid    bool
+	}{
+		{"same identity right away", identity, testEpoch, false, true},
+		{"at the end of its validity", identity, testEpoch.Add(LoginChallengeValidity), false, true},
+		{"after its validity", identity, testEpoch.Add(LoginChallengeValidity + time.Second), false, false},
+		{"already used", identity, testEpoch, true, false},
+		{"other client identity", UserIdentity{MSPID: "Org1MSP", ID: "x509::CN=mallory"}, testEpoch, false, false},
+		{"same identity of another organization", UserIdentity{MSPID: "Org2MSP", ID: identity.ID}, testEpoch, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			challenge := challenge
+			challenge.Used = test.used
+			if valid := challenge.IsValid(test.identity, test.at); valid != test.valid {
+				t.Fatalf("IsValid() = %v, want %v", valid, test.valid)
+			}
+		})
+	}
+}
+
+func TestCreateNewLoginChallenge(t *testing.T) {
+	identity := UserIdentity{MSPID: "Org1MSP", ID: "x509::CN=alice"}
+	challenge, _ := CreateNewLoginChallenge("tx1", "alice", identity, testEpoch)
+	other, _ := CreateNewLoginChallenge("tx2", "alice", identity, testEpoch)
+
+	if challenge.ChallengeID != "tx1" || challenge.Used {
+		t.Fatalf("challenge = %+v, want an unused challenge identified by its transaction", challenge)
+	}
+	if challenge.Nonce == "" || challenge.Nonce == other.Nonce {
+		t.Fatalf("nonces = %q and %q, want a distinct nonce per transaction", challenge.Nonce, other.Nonce)
+	}
+}
+
+func TestUserKeyValidAt(t *testing.T) {
+	key := UserKey{Name: "alice", ValidFrom: testEpoch}
+	revoked := key
+	revoked.Revoke(testEpoch.Add(time.Hour), "replaced")
+
+	tests := []struct {
+		name  string
+		key   UserKey
+		at    time.Time
+		valid bool
+	}{
+		{"before it was registered", key, testEpoch.Add(-time.Second), false},
+		{"when it was registered", key, testEpoch, true},
+		{"long after it was registered", key, testEpoch.Add(24 * 365 * time.Hour), true},
+		{"before it was revoked", revoked, testEpoch.Add(time.Hour - time.Second), true},
+		{"when it was revoked", revoked, testEpoch.Add(time.Hour), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if valid := test.key.ValidAt(test.at); valid != test.valid {
+				t.Fatalf("ValidAt() = %v, want %v", valid, test.valid)
+			}
+		})
+	}
+}
+
+func TestCommitSignedWithReplacedKey(t *testing.T) {
+	oldSigner := newEd25519TestSigner(t)
+	newSigner := newEd25519TestSigner(t)
+
+	oldKey, _ := CreateNewUserKey("alice", DefaultUserKeyLabel, oldSigner.publicKeyPEM, testEpoch)
+	oldKey.Revoke(testEpoch.Add(time.Hour), "replaced")
+	newKey, _ := CreateNewUserKey("alice", DefaultUserKeyLabel, newSigner.publicKeyPEM, testEpoch.Add(time.Hour))
+	keys := []UserKey{oldKey, newKey}
+
+	// signatures made before the key was replaced stay verifiable
+	before := testCommit("a", 30)
+	before.Signature = oldSigner.sign(before.SigningPayload())
+	if err := before.VerifySignature(keys); err != nil {
+		t.Fatalf("commit signed while the old key was valid rejected: %v", err)
+	}
+
+	after := testCommit("b", 90)
+	after.Signature = oldSigner.sign(after.SigningPayload())
+	if err := after.VerifySignature(keys); err == nil {
+		t.Fatalf("commit signed with the old key after it was replaced accepted")
+	}
+
+	// but new pushes cannot be dated back to when the old key was valid
+	if err := before.VerifyPushedSignature(keys, testEpoch.Add(2*time.Hour)); err == nil || before.Verified {
+		t.Fatalf("backdated commit signed with the replaced key accepted on push")
+	}
+	current := testCommit("c", 150)
+	current.Signature = newSigner.sign(current.SigningPayload())
+	if err := current.VerifyPushedSignature(keys, testEpoch.Add(3*time.Hour)); err != nil {
+		t.Fatalf("commit signed with the new key rejected on push: %v", err)
+	}
+}
+
+func TestVerifySignatureWithAnyKey(t *testing.T) {
+	laptop := newECDSATestSigner(t)
+	runner := newRSATestSigner(t)
+	laptopKey, _ := CreateNewUserKey("alice", "laptop", laptop.publicKeyPEM, testEpoch)
+	runnerKey, _ := CreateNewUserKey("alice", "ci", runner.publicKeyPEM, testEpoch)
+	message := []byte("payload")
+
+	key, err := VerifySignatureWithAnyKey([]UserKey{laptopKey, runnerKey}, message, runner.sign(message), SignatureFormatAuto)
+	if err != nil || key.Label != "ci" {
+		t.Fatalf("VerifySignatureWithAnyKey() = %q, %v, want the ci key", key.Label, err)
+	}
+
+	// revoking one device leaves the other keys active
+	laptopKey.Revoke(testEpoch.Add(time.Hour), "lost")
+	active := ActiveUserKeys([]UserKey{laptopKey, runnerKey})
+	if len(active) != 1 || active[0].Label != "ci" {
+		t.Fatalf("ActiveUserKeys() = %v, want the ci key only", active)
+	}
+	if _, err := VerifySignatureWithAnyKey(active, message, laptop.sign(message), SignatureFormatAuto); err == nil {
+		t.Fatalf("signature of the revoked key accepted by the active keys")
+	}
+
+	if _, err := VerifySignatureWithAnyKey(nil, message, runner.sign(message), SignatureFormatAuto); err == nil {
+		t.Fatalf("signature accepted without any key")
+	}
+}
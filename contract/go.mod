@@ -1,4 +1,4 @@
-module main
+module contract
 
 go 1.21
 
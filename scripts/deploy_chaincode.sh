@@ -22,6 +22,6 @@ peer lifecycle chaincode install contract.tar.gz
 peer lifecycle chaincode queryinstalled
 
 # Redeploy
-./network.sh deployCC -ccn contract -ccp ~/contract/ -ccl go -ccep "OR('Org1MSP.peer','Org2MSP.peer')"
+./network.sh deployCC -ccn contract -ccp ~/contract/ -ccl go -ccep "OR('Org1MSP.peer','Org2MSP.peer')" -cccg ~/contract/collections_config.json
 
 # To invoke, use the following command: peer chaincode invoke -o localhost:7050 --ordererTLSHostnameOverride orderer.example.com --tls --cafile "${PWD}/organizations/ordererOrganizations/example.com/orderers/orderer.example.com/msp/tlscacerts/tlsca.example.com-cert.pem" -C mychannel -n contract --peerAddresses localhost:7051 --tlsRootCertFiles "${PWD}/organizations/peerOrganizations/org1.example.com/peers/peer0.org1.example.com/tls/ca.crt" --peerAddresses localhost:9051 --tlsRootCertFiles "${PWD}/organizations/peerOrganizations/org2.example.com/peers/peer0.org2.example.com/tls/ca.crt" -c '{"function":"$FUNCTION_NAME","Args":[$ARGS]}'
\ No newline at end of file